@@ -0,0 +1,291 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// aggregateKey identifies a single aggregated stat: its kind, its full name,
+// its comma separated tags, and its sample rate
+type aggregateKey struct {
+	kind, name, tags string
+	rate             float64
+}
+
+// aggregate is the value of a single aggregated stat. Counts are summed,
+// gauges keep the last value, sets keep each unique value, and histograms,
+// timings, and distributions keep every sample.
+type aggregate struct {
+	value   float64
+	set     map[float64]struct{}
+	samples []float64
+}
+
+// aggregator holds stats in memory, and emits them every flush interval
+type aggregator struct {
+	mu    sync.Mutex
+	stats map[aggregateKey]*aggregate
+
+	// extended is whether histograms, timings, and distributions are
+	// aggregated as well
+	extended bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newAggregator(interval time.Duration, extended bool, g *Godspeed) *aggregator {
+	a := &aggregator{
+		stats:    make(map[aggregateKey]*aggregate),
+		extended: extended,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if interval > 0 {
+		go a.loop(interval, g)
+	} else {
+		close(a.done)
+	}
+
+	return a
+}
+
+// loop flushes the aggregates every interval until close() is called
+func (a *aggregator) loop(interval time.Duration, g *Godspeed) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			g.handleError(a.flush(g))
+		}
+	}
+}
+
+// aggregates returns whether stats of this kind are aggregated
+func (a *aggregator) aggregates(kind string) bool {
+	switch kind {
+	case "c", "g", "s":
+		return true
+	}
+
+	return a.extended && samples(kind)
+}
+
+// samples returns whether every value of this kind is kept when aggregated,
+// rather than being combined in to a single value
+func samples(kind string) bool {
+	switch kind {
+	case "h", "ms", "d":
+		return true
+	}
+
+	return false
+}
+
+// add includes value in the aggregate for the stat. The sample rate is only
+// kept for kinds where each sample is kept.
+func (a *aggregator) add(kind, name, tags string, value, sampleRate float64) {
+	key := aggregateKey{kind: kind, name: name, tags: tags, rate: 1}
+
+	if samples(kind) && sampleRate < 1 {
+		key.rate = sampleRate
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	agg, ok := a.stats[key]
+
+	if !ok {
+		agg = &aggregate{}
+		a.stats[key] = agg
+	}
+
+	switch kind {
+	case "c":
+		agg.value += value
+	case "g":
+		agg.value = value
+	case "s":
+		if agg.set == nil {
+			agg.set = make(map[float64]struct{})
+		}
+
+		agg.set[value] = struct{}{}
+	default:
+		agg.samples = append(agg.samples, value)
+	}
+}
+
+// flush emits each of the aggregates using g, and resets them. The stats are
+// emitted in a consistent order. It returns the first error hit, but still
+// tries to emit everything.
+func (a *aggregator) flush(g *Godspeed) (err error) {
+	a.mu.Lock()
+	stats := a.stats
+	a.stats = make(map[aggregateKey]*aggregate, len(stats))
+	a.mu.Unlock()
+
+	keys := make([]aggregateKey, 0, len(stats))
+
+	for k := range stats {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+
+		if keys[i].tags != keys[j].tags {
+			return keys[i].tags < keys[j].tags
+		}
+
+		return keys[i].rate < keys[j].rate
+	})
+
+	for _, k := range keys {
+		var lines []string
+
+		switch agg := stats[k]; {
+		case samples(k.kind):
+			lines = packValues(agg.samples, g.packetLimit()-lineOverhead(k, g.containerID()))
+		case k.kind == "s":
+			values := make([]float64, 0, len(agg.set))
+
+			for v := range agg.set {
+				values = append(values, v)
+			}
+
+			sort.Float64s(values)
+
+			for _, v := range values {
+				lines = append(lines, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		default:
+			lines = []string{strconv.FormatFloat(agg.value, 'f', -1, 64)}
+		}
+
+		for _, values := range lines {
+			serr := g.sendLine(k.name, k.name, values, k.kind, k.rate, k.tags, 0)
+
+			if serr != nil && err == nil {
+				err = serr
+			}
+		}
+	}
+
+	return
+}
+
+// lineOverhead returns the size of a stat line for k, excluding its values
+func lineOverhead(k aggregateKey, containerID string) int {
+	n := len(k.name) + 1 + 1 + len(k.kind)
+
+	if k.rate < 1 {
+		n += 2 + len(strconv.FormatFloat(k.rate, 'f', -1, 64))
+	}
+
+	if len(k.tags) > 0 {
+		n += 2 + len(k.tags)
+	}
+
+	if len(containerID) > 0 {
+		n += 3 + len(containerID)
+	}
+
+	return n
+}
+
+// packValues joins the values with colons, as used by multi-value stat lines
+// (DogStatsD protocol v1.1), splitting them so that no group is larger than
+// size. A value larger than size on its own gets a group to itself.
+func packValues(values []float64, size int) []string {
+	var groups []string
+	var buf []byte
+
+	for _, v := range values {
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+
+		if len(buf) > 0 && len(buf)+1+len(s) > size {
+			groups = append(groups, string(buf))
+			buf = buf[:0]
+		}
+
+		if len(buf) > 0 {
+			buf = append(buf, ':')
+		}
+
+		buf = append(buf, s...)
+	}
+
+	if len(buf) > 0 {
+		groups = append(groups, string(buf))
+	}
+
+	return groups
+}
+
+// close stops the flush loop and emits anything left
+func (a *aggregator) close(g *Godspeed) error {
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+
+	<-a.done
+
+	return a.flush(g)
+}
+
+// EnableAggregation has Godspeed aggregate counts, gauges, and sets in memory
+// instead of emitting each one. Counts (including Incr and Decr) are summed,
+// gauges keep the last value, and sets keep each unique value, for each
+// distinct stat name and set of tags. The aggregates are emitted every
+// flushInterval, and when Flush() or Close() are called. A flushInterval less
+// than 1 only emits them when asked to.
+//
+// Aggregated stats are never sampled, so the sample rate passed to Send() is
+// ignored for them. This should be called before the Godspeed instance is
+// used.
+func (g *Godspeed) EnableAggregation(flushInterval time.Duration) {
+	if g.aggregator != nil {
+		g.aggregator.close(g)
+	}
+
+	g.aggregator = newAggregator(flushInterval, false, g)
+}
+
+// EnableExtendedAggregation is like EnableAggregation, except histograms,
+// timings, and distributions are aggregated too. Every sample is kept, and
+// they're emitted as multi-value lines (name:1:2:3|h) that are split so each
+// line fits within a single datagram.
+//
+// Unlike the other aggregated kinds, these still honor the sample rate; the
+// samples are taken before they're aggregated, and the rate is included when
+// they're emitted.
+func (g *Godspeed) EnableExtendedAggregation(flushInterval time.Duration) {
+	if g.aggregator != nil {
+		g.aggregator.close(g)
+	}
+
+	g.aggregator = newAggregator(flushInterval, true, g)
+}
@@ -0,0 +1,184 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type AggregatorTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&AggregatorTestSuite{})
+
+func (t *AggregatorTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+	t.g = godspeed.NewWithTransport(t.m, false)
+	t.g.EnableAggregation(0)
+}
+
+func (t *AggregatorTestSuite) TestEnableAggregation(c *C) {
+	t.g.SetNamespace("godspeed")
+
+	//
+	// test that counts are summed per name and tags
+	//
+	c.Assert(t.g.Incr("test.count", nil), IsNil)
+	c.Assert(t.g.Incr("test.count", nil), IsNil)
+	c.Assert(t.g.Count("test.count", 5, nil), IsNil)
+	c.Assert(t.g.Decr("test.count", []string{"tag:a"}), IsNil)
+	c.Assert(t.g.Send("test.count", "c", 2, 0.01, []string{"tag:a"}), IsNil)
+
+	//
+	// test that gauges keep the last value
+	//
+	c.Assert(t.g.Gauge("test.gauge", 1, nil), IsNil)
+	c.Assert(t.g.Gauge("test.gauge", 42, nil), IsNil)
+
+	//
+	// test that sets keep unique values
+	//
+	c.Assert(t.g.Set("test.set", 2, nil), IsNil)
+	c.Assert(t.g.Set("test.set", 1, nil), IsNil)
+	c.Assert(t.g.Set("test.set", 2, nil), IsNil)
+
+	//
+	// test that other kinds aren't aggregated
+	//
+	c.Assert(t.g.Timing("test.timing", 3, nil), IsNil)
+	c.Assert(t.g.Timing("test.timing", 3, nil), IsNil)
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 2)
+	c.Check(writes[0], Equals, "godspeed.test.timing:3|ms")
+	c.Check(writes[1], Equals, "godspeed.test.timing:3|ms")
+
+	c.Assert(t.g.Flush(), IsNil)
+
+	c.Check(t.m.Writes()[2:], DeepEquals, []string{
+		"godspeed.test.count:7|c",
+		"godspeed.test.count:1|c|#tag:a",
+		"godspeed.test.gauge:42|g",
+		"godspeed.test.set:1|s",
+		"godspeed.test.set:2|s",
+	})
+
+	//
+	// test that aggregates are reset after each flush
+	//
+	c.Assert(t.g.Flush(), IsNil)
+	c.Check(len(t.m.Writes()), Equals, 7)
+
+	c.Assert(t.g.Incr("test.count", nil), IsNil)
+	c.Assert(t.g.Close(), IsNil)
+
+	writes = t.m.Writes()
+	c.Assert(len(writes), Equals, 8)
+	c.Check(writes[7], Equals, "godspeed.test.count:1|c")
+}
+
+func (t *AggregatorTestSuite) TestEnableAggregationBuffered(c *C) {
+	t.g.EnableBuffering(0, 0)
+
+	c.Assert(t.g.Incr("test.count", nil), IsNil)
+	c.Assert(t.g.Gauge("test.gauge", 1, nil), IsNil)
+	c.Assert(t.g.Flush(), IsNil)
+
+	// the aggregates should be emitted before the buffer is flushed
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 1)
+	c.Check(writes[0], Equals, "test.count:1|c\ntest.gauge:1|g")
+}
+
+func (t *AggregatorTestSuite) TestEnableAggregationInterval(c *C) {
+	t.g.EnableAggregation(time.Millisecond * 10)
+	defer t.g.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Assert(t.g.Incr("test.count", nil), IsNil)
+	}
+
+	deadline := time.Now().Add(time.Second)
+
+	for len(t.m.Writes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	writes := t.m.Writes()
+	c.Assert(len(writes) > 0, Equals, true)
+	c.Check(strings.HasPrefix(writes[0], "test.count:"), Equals, true)
+}
+
+func (t *AggregatorTestSuite) TestEnableExtendedAggregation(c *C) {
+	t.g.EnableExtendedAggregation(0)
+
+	//
+	// test that histograms, timings, and distributions keep every sample
+	//
+	c.Assert(t.g.Histogram("test.hist", 1, nil), IsNil)
+	c.Assert(t.g.Histogram("test.hist", 2, nil), IsNil)
+	c.Assert(t.g.Histogram("test.hist", 2, []string{"tag:a"}), IsNil)
+	c.Assert(t.g.Timing("test.timing", 3.5, nil), IsNil)
+	c.Assert(t.g.Timing("test.timing", 1, nil), IsNil)
+	c.Assert(t.g.Send("test.dist", "d", 7, 1, nil), IsNil)
+	c.Assert(t.g.Incr("test.count", nil), IsNil)
+
+	c.Check(len(t.m.Writes()), Equals, 0)
+	c.Assert(t.g.Flush(), IsNil)
+
+	c.Check(t.m.Writes(), DeepEquals, []string{
+		"test.count:1|c",
+		"test.dist:7|d",
+		"test.hist:1:2|h",
+		"test.hist:2|h|#tag:a",
+		"test.timing:3.5:1|ms",
+	})
+}
+
+func (t *AggregatorTestSuite) TestEnableExtendedAggregationSplit(c *C) {
+	t.g.EnableExtendedAggregation(0)
+
+	// 3000 samples take 11999 bytes, so they can't fit in one datagram
+	for i := 0; i < 3000; i++ {
+		c.Assert(t.g.Histogram("test.hist", 100, []string{"tag:a"}), IsNil)
+	}
+
+	c.Assert(t.g.Flush(), IsNil)
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 2)
+
+	var count int
+
+	for _, w := range writes {
+		c.Check(len(w) <= godspeed.MaxBytes, Equals, true)
+		c.Check(strings.HasPrefix(w, "test.hist:100:"), Equals, true)
+		c.Check(strings.HasSuffix(w, ":100|h|#tag:a"), Equals, true)
+
+		count += strings.Count(w, "100")
+	}
+
+	c.Check(count, Equals, 3000)
+}
+
+func (t *AggregatorTestSuite) TestTimestampsNotAggregated(c *C) {
+	ts := time.Unix(1431484263, 0)
+
+	c.Assert(t.g.GaugeWithTimestamp("test.gauge", 1, ts, nil), IsNil)
+	c.Assert(t.g.GaugeWithTimestamp("test.gauge", 2, ts, nil), IsNil)
+
+	c.Check(t.m.Writes(), DeepEquals, []string{
+		"test.gauge:1|g|T1431484263",
+		"test.gauge:2|g|T1431484263",
+	})
+}
@@ -4,11 +4,95 @@
 
 package godspeed
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultQueueSize is the number of emissions AsyncGodspeed will hold
+	// while waiting for them to be written
+	DefaultQueueSize = 4096
+
+	// DefaultWorkers is the number of goroutines AsyncGodspeed uses to
+	// write queued emissions
+	DefaultWorkers = 1
+)
+
+// AsyncError is the error passed to the error handler when an AsyncGodspeed
+// emission fails or is dropped, as there's no caller to return it to. See
+// WithErrorHandler() and SetErrorHandler().
+type AsyncError struct {
+	// Name is the stat name (without the namespace), event title, or
+	// service check name
+	Name string
+
+	// Kind is the stat kind ("c", "g", "ms", etc.), "event", or
+	// "service_check"
+	Kind string
+
+	// Err is the error returned by the Godspeed instance, or the reason the
+	// emission was dropped: ErrQueueFull or ErrClosed
+	Err error
+}
+
+func (e *AsyncError) Error() string {
+	return fmt.Sprintf("async %s %q: %v", e.Kind, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *AsyncError) Unwrap() error {
+	return e.Err
+}
+
+// asyncJob is a single queued emission
+type asyncJob struct {
+	fn func() error
+	y  *sync.WaitGroup
+
+	// dropped reports the job being dropped, and why
+	dropped func(err error)
+}
 
-// AsyncGodspeed is used for asynchronous Godspeed calls.
-// The AsyncGodspeed emission methods have an additional argument
-// for a *sync.WaitGroup to have the method indicate when finished.
+// asyncQueue is the queue, and the workers writing from it, used by an
+// AsyncGodspeed instance and any instances derived from it
+type asyncQueue struct {
+	jobs    chan asyncJob
+	block   bool
+	quit    chan struct{}
+	workers sync.WaitGroup
+
+	// mu protects the fields below, which track how many emissions have
+	// been queued but not yet written
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+	closed  bool
+
+	// dropped is the number of emissions dropped because the queue was full
+	// or closed
+	dropped uint64
+
+	// err is why the jobs still queued were abandoned, if they were; it's
+	// set before quit is closed
+	err error
+}
+
+// AsyncGodspeed is used for asynchronous Godspeed calls. Emissions are put
+// on a fixed-size queue and written by a fixed number of worker goroutines,
+// so the emission methods never wait on the network. When the queue is full
+// emissions are either dropped or wait for room, depending on how the
+// instance was built.
+//
+// The AsyncGodspeed emission methods have an additional argument for a
+// *sync.WaitGroup to have the method indicate when the emission has been
+// written. It may be nil if you don't need to know; Flush() can be used to
+// wait for everything queued to be written instead. As the emission methods
+// don't return errors, any errors are passed to the error handler instead
+// (see SetErrorHandler()), and dropped emissions are counted by Dropped().
 type AsyncGodspeed struct {
 	// Godspeed is an instance of Godspeed
 	Godspeed *Godspeed
@@ -18,33 +102,336 @@ type AsyncGodspeed struct {
 	// This is here as a convenience, and you can use your own WaitGroup
 	// in any AsyncGodspeed method calls.
 	W *sync.WaitGroup
+
+	// queue is nil if the instance wasn't built using one of the
+	// constructors, in which case emissions are written immediately
+	queue *asyncQueue
+
+	// derived is whether this instance was made using WithTags() or
+	// WithNamespace(), in which case it doesn't own the queue
+	derived bool
 }
 
 // NewAsync returns an instance of AsyncGodspeed. This is the more async-friendly version of Godspeed
 // autoTruncate dictactes whether long stats emissions get auto-truncated or dropped. Unfortunately,
 // Events will always be dropped. If you need monitor your events, you can access the Godspeed instance
 // directly.
+//
+// The instance has a queue of DefaultQueueSize emissions, written by DefaultWorkers
+// goroutines, and drops emissions when the queue is full.
 func NewAsync(host string, port int, autoTruncate bool) (a *AsyncGodspeed, err error) {
-	gs, err := New(host, port, autoTruncate)
+	a, err = NewAsyncClient(withLegacyAddress(host, port), WithAutoTruncate(autoTruncate))
+	return
+}
 
-	if err != nil {
-		return nil, err
+// NewAsyncWithTransport is like NewAsync except the underlying Godspeed
+// instance writes all emissions through t.
+func NewAsyncWithTransport(t Transport, autoTruncate bool) *AsyncGodspeed {
+	return NewAsyncWithQueue(NewWithTransport(t, autoTruncate), DefaultQueueSize, DefaultWorkers, false)
+}
+
+// NewAsyncWithQueue returns an instance of AsyncGodspeed which emits using g.
+// It queues up to queueSize emissions, which are written by the specified
+// number of worker goroutines. If block is true, emission methods wait for
+// room in the queue when it's full, otherwise the emission is dropped. Values
+// less than 1 for queueSize or workers use DefaultQueueSize and DefaultWorkers.
+func NewAsyncWithQueue(g *Godspeed, queueSize, workers int, block bool) *AsyncGodspeed {
+	if queueSize < 1 {
+		queueSize = DefaultQueueSize
 	}
 
-	a = &AsyncGodspeed{
-		Godspeed: gs,
-		W:        new(sync.WaitGroup),
+	if workers < 1 {
+		workers = DefaultWorkers
 	}
 
-	return
+	q := &asyncQueue{
+		jobs:  make(chan asyncJob, queueSize),
+		block: block,
+		quit:  make(chan struct{}),
+		idle:  make(chan struct{}),
+	}
+
+	// nothing is pending yet
+	close(q.idle)
+
+	q.workers.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return &AsyncGodspeed{
+		Godspeed: g,
+		W:        new(sync.WaitGroup),
+		queue:    q,
+	}
 }
 
 // NewDefaultAsync is just like NewAsync except it uses the DefaultHost and DefaultPort
 func NewDefaultAsync() (a *AsyncGodspeed, err error) {
-	a, err = NewAsync(DefaultHost, DefaultPort, false)
+	a, err = NewAsyncClient()
 	return
 }
 
+// work writes queued emissions until the queue is closed
+func (q *asyncQueue) work() {
+	defer q.workers.Done()
+
+	for {
+		select {
+		case j := <-q.jobs:
+			// a job may still be picked up once Close has given up
+			select {
+			case <-q.quit:
+				q.abandonJob(j)
+			default:
+				q.run(j)
+			}
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// run writes a single emission and marks it as done
+func (q *asyncQueue) run(j asyncJob) {
+	j.fn()
+	q.finish(j)
+}
+
+// finish marks a job as no longer pending
+func (q *asyncQueue) finish(j asyncJob) {
+	if j.y != nil {
+		j.y.Done()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+
+	if q.pending == 0 {
+		close(q.idle)
+	}
+}
+
+// enqueue puts an emission on the queue, dropping it if the queue is full
+// and isn't set to block. The error returned is why it was dropped.
+func (q *asyncQueue) enqueue(j asyncJob) error {
+	q.mu.Lock()
+
+	if q.closed {
+		q.mu.Unlock()
+
+		if j.y != nil {
+			j.y.Done()
+		}
+
+		atomic.AddUint64(&q.dropped, 1)
+
+		return ErrClosed
+	}
+
+	if q.pending == 0 {
+		q.idle = make(chan struct{})
+	}
+
+	q.pending++
+	q.mu.Unlock()
+
+	if q.block {
+		select {
+		case q.jobs <- j:
+			return nil
+		case <-q.quit:
+			q.drop(j)
+			return ErrClosed
+		}
+	}
+
+	select {
+	case q.jobs <- j:
+		return nil
+	default:
+		q.drop(j)
+		return ErrQueueFull
+	}
+}
+
+// drop counts a job as dropped, and marks it as no longer pending
+func (q *asyncQueue) drop(j asyncJob) {
+	atomic.AddUint64(&q.dropped, 1)
+	q.finish(j)
+}
+
+// wait blocks until there are no pending emissions, or ctx is done
+func (q *asyncQueue) wait(ctx context.Context) error {
+	q.mu.Lock()
+	idle := q.idle
+	q.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting new emissions, waits for the queued ones to be
+// written, and stops the workers
+func (q *asyncQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	closed := q.closed
+	q.closed = true
+	q.mu.Unlock()
+
+	if closed {
+		return nil
+	}
+
+	q.err = q.wait(ctx)
+
+	close(q.quit)
+	q.workers.Wait()
+
+	if q.err != nil {
+		q.abandon()
+	}
+
+	return q.err
+}
+
+// abandon drops the jobs left on the queue once the workers have stopped,
+// including any still being put on it, so that their WaitGroups are done
+func (q *asyncQueue) abandon() {
+	q.mu.Lock()
+	idle := q.idle
+	q.mu.Unlock()
+
+	for {
+		select {
+		case j := <-q.jobs:
+			q.abandonJob(j)
+		case <-idle:
+			return
+		}
+	}
+}
+
+// abandonJob drops a job which was still queued when Close gave up
+func (q *asyncQueue) abandonJob(j asyncJob) {
+	if j.dropped != nil {
+		j.dropped(q.err)
+	}
+
+	q.drop(j)
+}
+
+// enqueue puts an emission on the queue. If the instance wasn't built with a
+// queue the emission is written immediately. Any error, including the
+// emission being dropped, is passed to the error handler as an *AsyncError.
+func (a *AsyncGodspeed) enqueue(y *sync.WaitGroup, name, kind string, fn func() error) {
+	emit := func() error {
+		err := fn()
+
+		if err != nil {
+			a.Godspeed.handleError(&AsyncError{Name: name, Kind: kind, Err: err})
+		}
+
+		return err
+	}
+
+	if a.queue == nil {
+		emit()
+
+		if y != nil {
+			y.Done()
+		}
+
+		return
+	}
+
+	dropped := func(err error) {
+		a.Godspeed.telemetry.add(counterQueueDropped, 1)
+		a.Godspeed.handleError(&AsyncError{Name: name, Kind: kind, Err: err})
+	}
+
+	if err := a.queue.enqueue(asyncJob{fn: emit, y: y, dropped: dropped}); err != nil {
+		dropped(err)
+	}
+}
+
+// Dropped returns the number of emissions dropped because the queue was
+// full, or because Close() had been called. Instances made using WithTags()
+// or WithNamespace() share the count with the instance they came from.
+func (a *AsyncGodspeed) Dropped() uint64 {
+	if a.queue == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&a.queue.dropped)
+}
+
+// Flush waits for all queued emissions to be written, and then flushes the
+// Godspeed instance's buffer. It returns early with the context's error if
+// ctx is done first.
+func (a *AsyncGodspeed) Flush(ctx context.Context) error {
+	if a.queue != nil {
+		if err := a.queue.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	return a.Godspeed.Flush()
+}
+
+// Close stops accepting new emissions, waits for the queued ones to be
+// written, and closes the Godspeed instance. If ctx is done before the queue
+// is drained, the remaining emissions are dropped (see Dropped()) and the
+// context's error is returned. For instances made using WithTags() or WithNamespace() this is
+// the same as Flush(), as the queue belongs to the instance they were derived
+// from.
+func (a *AsyncGodspeed) Close(ctx context.Context) error {
+	if a.derived {
+		return a.Flush(ctx)
+	}
+
+	var err error
+
+	if a.queue != nil {
+		err = a.queue.close(ctx)
+	}
+
+	if cerr := a.Godspeed.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// WithNamespace returns a new AsyncGodspeed instance which uses the queue
+// of this one, with a Godspeed instance from Godspeed.WithNamespace()
+func (a *AsyncGodspeed) WithNamespace(ns string) *AsyncGodspeed {
+	return &AsyncGodspeed{
+		Godspeed: a.Godspeed.WithNamespace(ns),
+		W:        new(sync.WaitGroup),
+		queue:    a.queue,
+		derived:  true,
+	}
+}
+
+// WithTags returns a new AsyncGodspeed instance which uses the queue of
+// this one, with a Godspeed instance from Godspeed.WithTags()
+func (a *AsyncGodspeed) WithTags(tags ...string) *AsyncGodspeed {
+	return &AsyncGodspeed{
+		Godspeed: a.Godspeed.WithTags(tags...),
+		W:        new(sync.WaitGroup),
+		queue:    a.queue,
+		derived:  true,
+	}
+}
+
 // AddTag is identical to that within the Godspeed client
 func (a *AsyncGodspeed) AddTag(tag string) []string {
 	return a.Godspeed.AddTag(tag)
@@ -60,48 +447,69 @@ func (a *AsyncGodspeed) SetNamespace(ns string) {
 	a.Godspeed.SetNamespace(ns)
 }
 
+// SetErrorHandler is identical to that within the Godspeed client. The
+// handler is also given an *AsyncError for each emission that fails or is
+// dropped.
+func (a *AsyncGodspeed) SetErrorHandler(fn func(error)) {
+	a.Godspeed.SetErrorHandler(fn)
+}
+
 // Event is almost identical to that within the Godspeed client
 // The only chnage is that it has no return value, and takes a
 // (sync.WaitGroup) argument
 func (a *AsyncGodspeed) Event(title, body string, keys map[string]string, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
-
-	a.Godspeed.Event(title, body, keys, tags)
+	a.enqueue(y, title, "event", func() error {
+		return a.Godspeed.Event(title, body, keys, tags)
+	})
 }
 
 // Send is almost identical to that within the Godspeed client
 // with the addition of an argument and removal of the return value
 func (a *AsyncGodspeed) Send(stat, kind string, delta, sampleRate float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
+	a.enqueue(y, stat, kind, func() error {
+		return a.Godspeed.Send(stat, kind, delta, sampleRate, tags)
+	})
+}
 
-	a.Godspeed.Send(stat, kind, delta, sampleRate, tags)
+// SendWithTimestamp is almost identical to that within the Godspeed client
+// with the addition of an argument and removal of the return value
+func (a *AsyncGodspeed) SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string, y *sync.WaitGroup) {
+	a.enqueue(y, stat, kind, func() error {
+		return a.Godspeed.SendWithTimestamp(stat, kind, delta, timestamp, tags)
+	})
 }
 
 // ServiceCheck is almost identical to that within the Godspeed client
 // with the addition of an argument and removal of the return value
 func (a *AsyncGodspeed) ServiceCheck(name string, status int, fields map[string]string, tags []string, y *sync.WaitGroup) {
-	if y != nil {
-		defer y.Done()
-	}
-
-	a.Godspeed.ServiceCheck(name, status, fields, tags)
+	a.enqueue(y, name, "service_check", func() error {
+		return a.Godspeed.ServiceCheck(name, status, fields, tags)
+	})
 }
 
 // Count is almost identical to that within the Godspeed client
 // As with the other AsyncGodpseed functions it omits a return value and
 // takes a *sync.WaitGroup instance
 func (a *AsyncGodspeed) Count(stat string, count float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
+	a.enqueue(y, stat, "c", func() error {
+		return a.Godspeed.Count(stat, count, tags)
+	})
+}
 
-	a.Godspeed.Count(stat, count, tags)
+// CountWithTimestamp is almost identical to that within the Godspeed client,
+// except it has no return value and takes a *sync.WaitGroup argument.
+func (a *AsyncGodspeed) CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string, y *sync.WaitGroup) {
+	a.enqueue(y, stat, "c", func() error {
+		return a.Godspeed.CountWithTimestamp(stat, count, timestamp, tags)
+	})
 }
 
 // Incr is almost identical to that within the Godspeed client,
 // except it has no return value and takes a *sync.WaitGroup argument.
 func (a *AsyncGodspeed) Incr(stat string, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
-
-	a.Godspeed.Incr(stat, tags)
+	a.enqueue(y, stat, "c", func() error {
+		return a.Godspeed.Incr(stat, tags)
+	})
 }
 
 // Decr is almost identical to that within the Godspeed client. It has
@@ -110,38 +518,54 @@ func (a *AsyncGodspeed) Incr(stat string, tags []string, y *sync.WaitGroup) {
 // Also, I've gotten tired of typing "Xxx is almost identical to that within..." so congrats
 // on making it this far in to the docs.
 func (a *AsyncGodspeed) Decr(stat string, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
-
-	a.Godspeed.Decr(stat, tags)
+	a.enqueue(y, stat, "c", func() error {
+		return a.Godspeed.Decr(stat, tags)
+	})
 }
 
 // Gauge is almost identical to that within the Godspeed client.
 // Here it has no return value, and takes a *sync.WaitGroup argument
 func (a *AsyncGodspeed) Gauge(stat string, value float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
+	a.enqueue(y, stat, "g", func() error {
+		return a.Godspeed.Gauge(stat, value, tags)
+	})
+}
 
-	a.Godspeed.Gauge(stat, value, tags)
+// GaugeWithTimestamp is almost identical to that within the Godspeed client.
+// Here it has no return value, and takes a *sync.WaitGroup argument
+func (a *AsyncGodspeed) GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string, y *sync.WaitGroup) {
+	a.enqueue(y, stat, "g", func() error {
+		return a.Godspeed.GaugeWithTimestamp(stat, value, timestamp, tags)
+	})
 }
 
 // Histogram is almost identical to that within the Godspeed client.
 // Within AsyncGodspeed it has no return value, and also takes a *sync.WaitGroup argument
 func (a *AsyncGodspeed) Histogram(stat string, value float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
-
-	a.Godspeed.Histogram(stat, value, tags)
+	a.enqueue(y, stat, "h", func() error {
+		return a.Godspeed.Histogram(stat, value, tags)
+	})
 }
 
 // Timing is almost identical to that within the Godspeed client.
 // The return value is removed, and it takes a *sync.WaitGroup argument here
 func (a *AsyncGodspeed) Timing(stat string, value float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
+	a.enqueue(y, stat, "ms", func() error {
+		return a.Godspeed.Timing(stat, value, tags)
+	})
+}
 
-	a.Godspeed.Timing(stat, value, tags)
+// Distribution is almost identical to that within the Godspeed client,
+// aside from having no return value and taking a *sync.WaitGroup argument
+func (a *AsyncGodspeed) Distribution(stat string, value, sampleRate float64, tags []string, y *sync.WaitGroup) {
+	a.enqueue(y, stat, "d", func() error {
+		return a.Godspeed.Distribution(stat, value, sampleRate, tags)
+	})
 }
 
 // Set is almost identical to that within the Godspeed client
 func (a *AsyncGodspeed) Set(stat string, value float64, tags []string, y *sync.WaitGroup) {
-	defer y.Done()
-
-	a.Godspeed.Set(stat, value, tags)
+	a.enqueue(y, stat, "s", func() error {
+		return a.Godspeed.Set(stat, value, tags)
+	})
 }
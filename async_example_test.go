@@ -4,7 +4,12 @@
 
 package godspeed_test
 
-import "github.com/PagerDuty/godspeed"
+import (
+	"context"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+)
 
 func ExampleNewAsync() {
 	a, err := godspeed.NewAsync(godspeed.DefaultHost, godspeed.DefaultPort, false)
@@ -86,3 +91,40 @@ func ExampleAsyncGodspeed_Gauge() {
 
 	a.W.Wait()
 }
+
+func ExampleNewAsyncWithQueue() {
+	g, err := godspeed.NewDefault()
+
+	if err != nil {
+		// handle error
+	}
+
+	// queue up to 1024 emissions, written by 2 goroutines, and drop
+	// emissions when the queue is full
+	a := godspeed.NewAsyncWithQueue(g, 1024, 2, false)
+
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		a.Close(ctx)
+	}()
+
+	// no WaitGroup is needed if you don't care when it's written
+	a.Incr("example.counter", nil, nil)
+}
+
+func ExampleAsyncGodspeed_Flush() {
+	a, _ := godspeed.NewDefaultAsync()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// stop the workers and close the connection once finished
+	defer a.Close(ctx)
+
+	a.Gauge("example.gauge", 1, nil, nil)
+
+	// wait for the gauge to be written, without closing anything
+	a.Flush(ctx)
+}
@@ -5,8 +5,12 @@
 package godspeed_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/PagerDuty/godspeed"
@@ -133,14 +137,22 @@ func (t *ATestSuite) TestAsyncEvent(c *C) {
 }
 
 func (t *ATestSuite) TestAsyncSend(c *C) {
-	t.g.W.Add(1)
-	go t.g.Send("test.stat", "g", 42, 0.99, extraTestTags, t.g.W)
+	m := &memTransport{}
+	a, err := godspeed.NewAsyncClient(
+		godspeed.WithTransport(m),
+		godspeed.WithNamespace("godspeed"),
+		godspeed.WithGlobalTags("test0", "test1"),
+		alwaysSample,
+	)
+	c.Assert(err, IsNil)
 
-	a, ok := <-t.o
-	c.Assert(ok, Equals, true)
+	defer a.Close(context.Background())
 
-	b := []byte("godspeed.test.stat:42|g|@0.99|#test0,test1,test8,test9")
-	c.Check(string(a), Equals, string(b))
+	a.W.Add(1)
+	go a.Send("test.stat", "g", 42, 0.99, extraTestTags, a.W)
+	a.W.Wait()
+
+	c.Check(m.Writes(), DeepEquals, []string{"godspeed.test.stat:42|g|@0.99|#test0,test1,test8,test9"})
 }
 
 func (t *ATestSuite) TestAsyncServiceCheck(c *C) {
@@ -201,6 +213,31 @@ func (t *ATestSuite) TestAsyncGauge(c *C) {
 	c.Check(string(a), Equals, string(b))
 }
 
+func (t *ATestSuite) TestAsyncWithTimestamp(c *C) {
+	ts := time.Unix(1431484263, 0)
+
+	t.g.W.Add(1)
+	go t.g.GaugeWithTimestamp("test.gauge", 42, ts, extraTestTags, t.g.W)
+
+	a, ok := <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "godspeed.test.gauge:42|g|#test0,test1,test8,test9|T1431484263")
+
+	t.g.W.Add(1)
+	go t.g.CountWithTimestamp("test.count", 2, ts, nil, t.g.W)
+
+	a, ok = <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "godspeed.test.count:2|c|#test0,test1|T1431484263")
+
+	t.g.W.Add(1)
+	go t.g.SendWithTimestamp("test.count", "c", 3, ts, nil, t.g.W)
+
+	a, ok = <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "godspeed.test.count:3|c|#test0,test1|T1431484263")
+}
+
 func (t *ATestSuite) TestAsyncHistogram(c *C) {
 	t.g.W.Add(1)
 	go t.g.Histogram("test.hist", 2, extraTestTags, t.g.W)
@@ -223,6 +260,27 @@ func (t *ATestSuite) TestAsyncTiming(c *C) {
 	c.Check(string(a), Equals, string(b))
 }
 
+func (t *ATestSuite) TestAsyncDistribution(c *C) {
+	t.g.W.Add(1)
+	go t.g.Distribution("test.dist", 5, 0.5, extraTestTags, t.g.W)
+	t.g.W.Wait()
+
+	t.g.W.Add(1)
+	go t.g.Distribution("test.dist", 5, 1, extraTestTags, t.g.W)
+
+	a, ok := <-t.o
+	c.Assert(ok, Equals, true)
+
+	// the first one may or may not have been sampled
+	if string(a) == "godspeed.test.dist:5|d|@0.5|#test0,test1,test8,test9" {
+		a, ok = <-t.o
+		c.Assert(ok, Equals, true)
+	}
+
+	b := []byte("godspeed.test.dist:5|d|#test0,test1,test8,test9")
+	c.Check(string(a), Equals, string(b))
+}
+
 func (t *ATestSuite) TestAsyncSet(c *C) {
 	t.g.W.Add(1)
 	go t.g.Set("test.set", 4, extraTestTags, t.g.W)
@@ -233,3 +291,297 @@ func (t *ATestSuite) TestAsyncSet(c *C) {
 	b := []byte("godspeed.test.set:4|s|#test0,test1,test8,test9")
 	c.Check(string(a), Equals, string(b))
 }
+
+func (t *ATestSuite) TestAsyncNilWaitGroup(c *C) {
+	t.g.Incr("test.incr", extraTestTags, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(t.g.Flush(ctx), IsNil)
+
+	a, ok := <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "godspeed.test.incr:1|c|#test0,test1,test8,test9")
+}
+
+// gateTransport is a memTransport whose writes wait until the gate is opened
+type gateTransport struct {
+	memTransport
+	gate    chan struct{}
+	waiting chan struct{}
+}
+
+func newGateTransport() *gateTransport {
+	return &gateTransport{
+		gate:    make(chan struct{}),
+		waiting: make(chan struct{}, 16),
+	}
+}
+
+func (g *gateTransport) Write(b []byte) (int, error) {
+	select {
+	case g.waiting <- struct{}{}:
+	default:
+	}
+
+	<-g.gate
+
+	return g.memTransport.Write(b)
+}
+
+func (t *ATestSuite) TestAsyncQueueDrop(c *C) {
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 1, 1, false)
+
+	wg := new(sync.WaitGroup)
+
+	// the worker picks up the first emission and waits on the gate, the
+	// second fills the queue, and the rest get dropped
+	wg.Add(1)
+	a.Incr("test.incr0", nil, wg)
+	<-gt.waiting
+
+	wg.Add(3)
+	a.Incr("test.incr1", nil, wg)
+	a.Incr("test.incr2", nil, wg)
+	a.Incr("test.incr3", nil, wg)
+
+	close(gt.gate)
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(a.Close(ctx), IsNil)
+	c.Check(gt.Writes(), DeepEquals, []string{"test.incr0:1|c", "test.incr1:1|c"})
+	c.Check(gt.closed, Equals, true)
+
+	// emissions after closing are dropped
+	a.Incr("test.incr4", nil, nil)
+	c.Check(len(gt.Writes()), Equals, 2)
+}
+
+func (t *ATestSuite) TestAsyncErrorHandler(c *C) {
+	var mu sync.Mutex
+	var errs []error
+
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 1, 1, false)
+	a.SetErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+	})
+
+	a.Incr("test.incr0", nil, nil)
+	<-gt.waiting
+
+	// this one fills the queue, but is too large to send
+	a.WithNamespace("example").Event(strings.Repeat("a", 8192), "b", nil, nil, nil)
+
+	// the queue is full, so this one is dropped
+	a.Gauge("test.gauge", 42, nil, nil)
+
+	close(gt.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(a.Close(ctx), IsNil)
+
+	a.ServiceCheck("testSvc", 0, nil, nil, nil)
+
+	c.Check(a.Dropped(), Equals, uint64(2))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	c.Assert(len(errs), Equals, 3)
+
+	var ae *godspeed.AsyncError
+
+	c.Assert(errors.As(errs[0], &ae), Equals, true)
+	c.Check(ae.Name, Equals, "test.gauge")
+	c.Check(ae.Kind, Equals, "g")
+	c.Check(errors.Is(ae.Err, godspeed.ErrQueueFull), Equals, true)
+	c.Check(errs[0], ErrorMatches, `async g "test.gauge": async queue is full`)
+
+	c.Assert(errors.As(errs[1], &ae), Equals, true)
+	c.Check(ae.Name, Equals, strings.Repeat("a", 8192))
+	c.Check(ae.Kind, Equals, "event")
+	c.Check(ae.Err, ErrorMatches, "error sending a+, packet larger than 8192 .*")
+
+	c.Assert(errors.As(errs[2], &ae), Equals, true)
+	c.Check(ae.Name, Equals, "testSvc")
+	c.Check(ae.Kind, Equals, "service_check")
+	c.Check(errors.Is(ae.Err, godspeed.ErrClosed), Equals, true)
+}
+
+func (t *ATestSuite) TestAsyncErrorHandlerDerived(c *C) {
+	var mu sync.Mutex
+	var parent, derived []error
+
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(&memTransport{}, false), 1, 10, false)
+	d := a.WithTags("derived")
+
+	// set on the parent after deriving, so the derived instance uses it too
+	a.SetErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		parent = append(parent, err)
+	})
+
+	d.ServiceCheck("svc0", 9, nil, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(d.Flush(ctx), IsNil)
+
+	// and set on the derived instance, so the parent uses it too
+	d.SetErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		derived = append(derived, err)
+	})
+
+	a.ServiceCheck("svc1", 9, nil, nil, nil)
+
+	c.Assert(a.Close(ctx), IsNil)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	c.Assert(len(parent), Equals, 1)
+	c.Check(parent[0], ErrorMatches, `async service_check "svc0": .*`)
+
+	c.Assert(len(derived), Equals, 1)
+	c.Check(derived[0], ErrorMatches, `async service_check "svc1": .*`)
+}
+
+func (t *ATestSuite) TestAsyncQueueBlock(c *C) {
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 1, 1, true)
+
+	done := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 5; i++ {
+			a.Incr("test.incr", nil, nil)
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		c.Fatal("enqueueing should block while the queue is full")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(gt.gate)
+	<-done
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(a.Close(ctx), IsNil)
+	c.Check(len(gt.Writes()), Equals, 5)
+}
+
+func (t *ATestSuite) TestAsyncCloseTimeout(c *C) {
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 1, 1, false)
+
+	a.Incr("test.incr", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	// let the worker finish once Close has given up on it
+	time.AfterFunc(time.Millisecond*50, func() { close(gt.gate) })
+
+	c.Check(a.Close(ctx), Equals, context.DeadlineExceeded)
+}
+
+func (t *ATestSuite) TestAsyncCloseTimeoutDrops(c *C) {
+	var errs []error
+
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 2, 1, false)
+	a.SetErrorHandler(func(err error) { errs = append(errs, err) })
+
+	wg := new(sync.WaitGroup)
+	wg.Add(3)
+
+	a.Incr("test.incr0", nil, wg)
+	<-gt.waiting
+
+	// these two are still queued when Close gives up
+	a.Incr("test.incr1", nil, wg)
+	a.Incr("test.incr2", nil, wg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	time.AfterFunc(time.Millisecond*50, func() { close(gt.gate) })
+
+	c.Check(a.Close(ctx), Equals, context.DeadlineExceeded)
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		c.Fatal("the WaitGroup wasn't done for the abandoned emissions")
+	}
+
+	c.Check(a.Dropped(), Equals, uint64(2))
+	c.Check(a.Stats().QueueDropped, Equals, uint64(2))
+	c.Check(gt.Writes(), DeepEquals, []string{"test.incr0:1|c"})
+
+	c.Assert(len(errs), Equals, 2)
+	c.Check(errs[0], ErrorMatches, `async c "test.incr1": context deadline exceeded`)
+}
+
+func (t *ATestSuite) TestAsyncWithNamespaceAndTags(c *C) {
+	m := &memTransport{}
+	a := godspeed.NewAsyncWithTransport(m, false)
+
+	db := a.WithNamespace("db").WithTags("shard:3")
+
+	a.Incr("requests", nil, nil)
+	db.Incr("queries", nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// closing the child should wait on the shared queue, but leave it open
+	c.Assert(db.Close(ctx), IsNil)
+	c.Check(len(m.Writes()), Equals, 2)
+	c.Check(m.closed, Equals, false)
+
+	a.Incr("requests", nil, nil)
+
+	c.Assert(a.Close(ctx), IsNil)
+	c.Check(m.closed, Equals, true)
+
+	writes := m.Writes()
+	c.Assert(len(writes), Equals, 3)
+	c.Check(writes[2], Equals, "requests:1|c")
+
+	if writes[0] != "requests:1|c" {
+		writes[0], writes[1] = writes[1], writes[0]
+	}
+
+	c.Check(writes[:2], DeepEquals, []string{"requests:1|c", "db.queries:1|c|#shard:3"})
+}
@@ -0,0 +1,149 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"sync"
+	"time"
+)
+
+// packetBuffer packs multiple emissions, separated by newlines, into a single
+// datagram. The datagram is written once adding another emission would make
+// it larger than size, when Flush() is called, or every flush interval.
+type packetBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+	w    func([]byte) (int, error)
+
+	// onError is called with errors hit writing emissions that were already
+	// buffered, either by the flush loop or to make room for a new emission
+	onError func(error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newPacketBuffer(size int, interval time.Duration, w func([]byte) (int, error), onError func(error)) *packetBuffer {
+	pb := &packetBuffer{
+		buf:     make([]byte, 0, size),
+		size:    size,
+		w:       w,
+		onError: onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if interval > 0 {
+		go pb.loop(interval)
+	} else {
+		close(pb.done)
+	}
+
+	return pb
+}
+
+// loop flushes the buffer every interval until close() is called
+func (pb *packetBuffer) loop(interval time.Duration) {
+	defer close(pb.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pb.stop:
+			return
+		case <-ticker.C:
+			pb.handleError(pb.Flush())
+		}
+	}
+}
+
+// Write adds a single emission to the buffer, flushing the buffer first if
+// there isn't room for it. Emissions larger than the buffer are written as-is.
+// An error flushing the emissions already in the buffer belongs to them, not
+// this one, so it's passed to onError rather than returned.
+func (pb *packetBuffer) Write(b []byte) (int, error) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if len(pb.buf) > 0 && len(pb.buf)+len(b)+1 > pb.size {
+		pb.handleError(pb.flush())
+	}
+
+	if len(b) > pb.size {
+		return pb.w(b)
+	}
+
+	if len(pb.buf) > 0 {
+		pb.buf = append(pb.buf, '\n')
+	}
+
+	pb.buf = append(pb.buf, b...)
+
+	return len(b), nil
+}
+
+// handleError passes a non-nil error to onError, if there is one
+func (pb *packetBuffer) handleError(err error) {
+	if err != nil && pb.onError != nil {
+		pb.onError(err)
+	}
+}
+
+// Flush writes anything currently in the buffer
+func (pb *packetBuffer) Flush() error {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	return pb.flush()
+}
+
+// flush is Flush() without the locking; pb.mu must be held
+func (pb *packetBuffer) flush() error {
+	if len(pb.buf) == 0 {
+		return nil
+	}
+
+	_, err := pb.w(pb.buf)
+	pb.buf = pb.buf[:0]
+
+	return err
+}
+
+// close stops the flush loop and writes anything left in the buffer
+func (pb *packetBuffer) close() error {
+	select {
+	case <-pb.stop:
+	default:
+		close(pb.stop)
+	}
+
+	<-pb.done
+
+	return pb.Flush()
+}
+
+// EnableBuffering has Godspeed pack emissions into datagrams of up to size
+// bytes instead of writing each one on its own, which saves a lot of syscalls
+// (and dropped packets) at high volume. Buffered emissions are written when
+// the datagram is full, every flushInterval, and when Flush() or Close() are
+// called. A size less than 1, or larger than the packet limit for the
+// transport, uses the packet limit. A flushInterval less than 1 only flushes
+// when full or asked to.
+//
+// This should be called before the Godspeed instance is used.
+func (g *Godspeed) EnableBuffering(size int, flushInterval time.Duration) {
+	if limit := g.packetLimit(); size < 1 || size > limit {
+		size = limit
+	}
+
+	if g.buffer != nil {
+		g.buffer.close()
+	}
+
+	g.buffer = newPacketBuffer(size, flushInterval, g.writeTransport, g.handleError)
+}
@@ -0,0 +1,130 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type BufferTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&BufferTestSuite{})
+
+func (t *BufferTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+	t.g = godspeed.NewWithTransport(t.m, false)
+}
+
+func (t *BufferTestSuite) TestEnableBuffering(c *C) {
+	t.g.EnableBuffering(64, 0)
+
+	//
+	// test that emissions are held until the packet would be too large
+	//
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+	c.Assert(t.g.Gauge("test.gauge", 42, nil), IsNil)
+	c.Assert(t.g.Event("a", "b", nil, nil), IsNil)
+	c.Assert(t.g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+
+	c.Check(len(t.m.Writes()), Equals, 0)
+
+	c.Assert(t.g.Timing("test.timing", 2054, nil), IsNil)
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 1)
+	c.Check(writes[0], Equals, "test.incr:1|c\ntest.gauge:42|g\n_e{1,1}:a|b\n_sc|testSvc|0")
+
+	//
+	// test that Flush() writes whatever is left
+	//
+	c.Assert(t.g.Flush(), IsNil)
+
+	writes = t.m.Writes()
+	c.Assert(len(writes), Equals, 2)
+	c.Check(writes[1], Equals, "test.timing:2054|ms")
+
+	// flushing an empty buffer shouldn't write anything
+	c.Assert(t.g.Flush(), IsNil)
+	c.Check(len(t.m.Writes()), Equals, 2)
+
+	//
+	// test that emissions larger than the buffer are written on their own
+	//
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+	c.Assert(t.g.Incr(strings.Repeat("a", 70), nil), IsNil)
+
+	writes = t.m.Writes()
+	c.Assert(len(writes), Equals, 4)
+	c.Check(writes[2], Equals, "test.incr:1|c")
+	c.Check(writes[3], Equals, strings.Repeat("a", 70)+":1|c")
+
+	//
+	// test that Close() flushes the buffer
+	//
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+	c.Assert(t.g.Close(), IsNil)
+
+	writes = t.m.Writes()
+	c.Assert(len(writes), Equals, 5)
+	c.Check(writes[4], Equals, "test.incr:1|c")
+	c.Check(t.m.closed, Equals, true)
+}
+
+func (t *BufferTestSuite) TestEnableBufferingInterval(c *C) {
+	t.g.EnableBuffering(0, time.Millisecond*10)
+	defer t.g.Close()
+
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+	c.Assert(t.g.Decr("test.decr", nil), IsNil)
+
+	deadline := time.Now().Add(time.Second)
+
+	for len(t.m.Writes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the ticker may fire between the two emissions, so don't depend on
+	// them being packed together
+	time.Sleep(time.Millisecond * 20)
+	c.Check(strings.Join(t.m.Writes(), "\n"), Equals, "test.incr:1|c\ntest.decr:-1|c")
+}
+
+func (t *BufferTestSuite) TestEnableBufferingErrors(c *C) {
+	var errs []error
+
+	g := godspeed.NewWithTransport(errTransport{}, false)
+	g.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	g.EnableBuffering(32, 0)
+
+	//
+	// test that failing to write the earlier emissions isn't blamed on the
+	// emission that made room for itself
+	//
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(g.Gauge("test.gauge.with.long.name", 42, nil), IsNil)
+
+	c.Assert(len(errs), Equals, 1)
+	c.Check(errs[0], ErrorMatches, "write failed")
+
+	// an emission written on its own still returns its own error
+	c.Check(g.Incr(strings.Repeat("a", 40), nil), ErrorMatches, "write failed")
+	c.Check(len(errs), Equals, 2)
+
+	c.Check(g.Stats().Metrics, Equals, uint64(2))
+	c.Check(g.Stats().WriteErrors, Equals, uint64(3))
+}
+
+func (t *BufferTestSuite) TestFlushUnbuffered(c *C) {
+	c.Check(t.g.Flush(), IsNil)
+}
@@ -0,0 +1,156 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"context"
+	"time"
+)
+
+// Client is the set of emission methods shared by Godspeed and the other
+// clients in this package, so code can accept any of them: a *Godspeed, the
+// Client() of an *AsyncGodspeed, a *Recorder in tests, a *Noop when stats are
+// turned off, or an implementation of your own (such as one sending to
+// multiple destinations).
+//
+// WithTags() and WithNamespace() aren't part of it, as each client returns
+// its own type from them.
+type Client interface {
+	Send(stat, kind string, delta, sampleRate float64, tags []string) error
+	SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string) error
+
+	Count(stat string, count float64, tags []string) error
+	CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string) error
+	Incr(stat string, tags []string) error
+	Decr(stat string, tags []string) error
+	Gauge(stat string, value float64, tags []string) error
+	GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string) error
+	Histogram(stat string, value float64, tags []string) error
+	Timing(stat string, value float64, tags []string) error
+	Distribution(stat string, value, sampleRate float64, tags []string) error
+	Set(stat string, value float64, tags []string) error
+
+	Event(title, text string, fields map[string]string, tags []string) error
+	ServiceCheck(name string, status int, fields map[string]string, tags []string) error
+
+	AddTag(tag string) []string
+	AddTags(tags []string) []string
+	SetNamespace(ns string)
+
+	Flush() error
+	Close() error
+}
+
+var (
+	_ Client = (*Godspeed)(nil)
+	_ Client = (*Recorder)(nil)
+	_ Client = (*Noop)(nil)
+	_ Client = asyncClient{}
+)
+
+// Client returns a Client which emits using this AsyncGodspeed instance,
+// without the *sync.WaitGroup arguments. Emissions are queued the same way,
+// so its methods always return nil; errors, including those for invalid
+// emissions, are passed to the error handler instead (see
+// SetErrorHandler()). Its Flush() and Close() wait for the queue without a
+// deadline.
+func (a *AsyncGodspeed) Client() Client {
+	return asyncClient{a: a}
+}
+
+// asyncClient adapts an AsyncGodspeed to the Client interface
+type asyncClient struct {
+	a *AsyncGodspeed
+}
+
+func (c asyncClient) Send(stat, kind string, delta, sampleRate float64, tags []string) error {
+	c.a.Send(stat, kind, delta, sampleRate, tags, nil)
+	return nil
+}
+
+func (c asyncClient) SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string) error {
+	c.a.SendWithTimestamp(stat, kind, delta, timestamp, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Count(stat string, count float64, tags []string) error {
+	c.a.Count(stat, count, tags, nil)
+	return nil
+}
+
+func (c asyncClient) CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string) error {
+	c.a.CountWithTimestamp(stat, count, timestamp, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Incr(stat string, tags []string) error {
+	c.a.Incr(stat, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Decr(stat string, tags []string) error {
+	c.a.Decr(stat, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Gauge(stat string, value float64, tags []string) error {
+	c.a.Gauge(stat, value, tags, nil)
+	return nil
+}
+
+func (c asyncClient) GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string) error {
+	c.a.GaugeWithTimestamp(stat, value, timestamp, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Histogram(stat string, value float64, tags []string) error {
+	c.a.Histogram(stat, value, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Timing(stat string, value float64, tags []string) error {
+	c.a.Timing(stat, value, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Distribution(stat string, value, sampleRate float64, tags []string) error {
+	c.a.Distribution(stat, value, sampleRate, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Set(stat string, value float64, tags []string) error {
+	c.a.Set(stat, value, tags, nil)
+	return nil
+}
+
+func (c asyncClient) Event(title, text string, fields map[string]string, tags []string) error {
+	c.a.Event(title, text, fields, tags, nil)
+	return nil
+}
+
+func (c asyncClient) ServiceCheck(name string, status int, fields map[string]string, tags []string) error {
+	c.a.ServiceCheck(name, status, fields, tags, nil)
+	return nil
+}
+
+func (c asyncClient) AddTag(tag string) []string {
+	return c.a.AddTag(tag)
+}
+
+func (c asyncClient) AddTags(tags []string) []string {
+	return c.a.AddTags(tags)
+}
+
+func (c asyncClient) SetNamespace(ns string) {
+	c.a.SetNamespace(ns)
+}
+
+func (c asyncClient) Flush() error {
+	return c.a.Flush(context.Background())
+}
+
+func (c asyncClient) Close() error {
+	return c.a.Close(context.Background())
+}
@@ -0,0 +1,115 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type ClientTestSuite struct{}
+
+var _ = Suite(&ClientTestSuite{})
+
+// emitAll uses each of the emission methods of a Client
+func emitAll(c *C, cl godspeed.Client) {
+	ts := time.Unix(1657100430, 0)
+
+	c.Check(cl.AddTags([]string{"env:test"}), DeepEquals, []string{"env:test"})
+	c.Check(cl.AddTag("app"), DeepEquals, []string{"env:test", "app"})
+	cl.SetNamespace("ns")
+
+	c.Check(cl.Send("send", "c", 1, 1, nil), IsNil)
+	c.Check(cl.SendWithTimestamp("send.ts", "g", 1, ts, nil), IsNil)
+	c.Check(cl.Count("count", 2, nil), IsNil)
+	c.Check(cl.CountWithTimestamp("count.ts", 2, ts, nil), IsNil)
+	c.Check(cl.Incr("incr", nil), IsNil)
+	c.Check(cl.Decr("decr", nil), IsNil)
+	c.Check(cl.Gauge("gauge", 3, nil), IsNil)
+	c.Check(cl.GaugeWithTimestamp("gauge.ts", 3, ts, nil), IsNil)
+	c.Check(cl.Histogram("hist", 4, nil), IsNil)
+	c.Check(cl.Timing("timing", 5, nil), IsNil)
+	c.Check(cl.Distribution("dist", 6, 1, nil), IsNil)
+	c.Check(cl.Set("set", 7, []string{"a"}), IsNil)
+	c.Check(cl.Event("title", "text", nil, nil), IsNil)
+	c.Check(cl.ServiceCheck("svc", 0, nil, nil), IsNil)
+
+	c.Check(cl.Flush(), IsNil)
+	c.Check(cl.Close(), IsNil)
+}
+
+// expectedLines are the lines emitAll() emits
+var expectedLines = []string{
+	"ns.send:1|c|#env:test,app",
+	"ns.send.ts:1|g|#env:test,app|T1657100430",
+	"ns.count:2|c|#env:test,app",
+	"ns.count.ts:2|c|#env:test,app|T1657100430",
+	"ns.incr:1|c|#env:test,app",
+	"ns.decr:-1|c|#env:test,app",
+	"ns.gauge:3|g|#env:test,app",
+	"ns.gauge.ts:3|g|#env:test,app|T1657100430",
+	"ns.hist:4|h|#env:test,app",
+	"ns.timing:5|ms|#env:test,app",
+	"ns.dist:6|d|#env:test,app",
+	"ns.set:7|s|#env:test,app,a",
+	"_e{5,4}:title|text|#env:test,app",
+	"_sc|svc|0|#env:test,app",
+}
+
+func (t *ClientTestSuite) TestGodspeed(c *C) {
+	m := &memTransport{}
+
+	emitAll(c, godspeed.NewWithTransport(m, false))
+
+	c.Check(m.Writes(), DeepEquals, expectedLines)
+	c.Check(m.closed, Equals, true)
+}
+
+func (t *ClientTestSuite) TestAsyncGodspeed(c *C) {
+	m := &memTransport{}
+
+	a, err := godspeed.NewAsyncClient(godspeed.WithTransport(m), godspeed.WithWorkers(1))
+	c.Assert(err, IsNil)
+
+	emitAll(c, a.Client())
+
+	c.Check(m.Writes(), DeepEquals, expectedLines)
+	c.Check(m.closed, Equals, true)
+}
+
+func (t *ClientTestSuite) TestAsyncGodspeedErrors(c *C) {
+	var errs []error
+
+	a, err := godspeed.NewAsyncClient(
+		godspeed.WithTransport(&memTransport{}),
+		godspeed.WithWorkers(1),
+		godspeed.WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	c.Assert(err, IsNil)
+
+	cl := a.Client()
+
+	// the error goes to the error handler, rather than being returned
+	c.Check(cl.ServiceCheck("svc", 9, nil, nil), IsNil)
+	c.Assert(cl.Close(), IsNil)
+
+	c.Assert(len(errs), Equals, 1)
+	c.Check(errs[0], ErrorMatches, `async service_check "svc": unknown service status \(9\).*`)
+}
+
+func (t *ClientTestSuite) TestRecorder(c *C) {
+	r := godspeed.NewRecorder()
+
+	emitAll(c, r)
+
+	c.Check(len(r.Metrics()), Equals, 12)
+	c.Check(len(r.Events()), Equals, 1)
+	c.Check(len(r.ServiceChecks()), Equals, 1)
+	c.Check(r.Incr("incr", nil), Equals, godspeed.ErrClosed)
+}
@@ -0,0 +1,110 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+// Package dogstatsd parses the DogStatsD wire format emitted by Godspeed:
+// stats (including the multi-value lines of protocol v1.1), events, and
+// service checks, with their sample rates, tags, container IDs, timestamps,
+// and optional fields. It's meant for building tooling, proxies, and tests on
+// the same rules Godspeed emits by.
+//
+// http://docs.datadoghq.com/guides/dogstatsd/#datagram-format
+package dogstatsd
+
+import "fmt"
+
+// Metric is a single stat
+type Metric struct {
+	// Name is the full name of the stat, including any namespace
+	Name string
+
+	// Kind is the stat kind: "c", "g", "ms", "h", "s", or "d"
+	Kind string
+
+	// Values has the value of the stat; multi-value lines (name:1:2:3|h)
+	// have more than one. For sets, whose members may be strings, it's
+	// only set if every member is a number.
+	Values []float64
+
+	// RawValues has the members of a set (s) as sent, which may be
+	// strings such as user names; it's nil for other kinds
+	RawValues []string
+
+	// SampleRate is the sample rate, or 1 if none was given
+	SampleRate float64
+
+	// Tags are the tags, in the order they were sent
+	Tags []string
+
+	// ContainerID is the container ID used for origin detection, if any
+	ContainerID string
+
+	// Timestamp is the Unix timestamp the stat was sent with, or 0
+	Timestamp int64
+}
+
+// Event is a single event
+type Event struct {
+	// Title and Text have had their newlines unescaped
+	Title string
+	Text  string
+
+	// Timestamp is the date_happened field, or 0 if it wasn't given
+	Timestamp int64
+
+	Hostname       string
+	AggregationKey string
+	Priority       string
+	SourceType     string
+	AlertType      string
+
+	Tags        []string
+	ContainerID string
+}
+
+// ServiceCheck is a single service check
+type ServiceCheck struct {
+	Name string
+
+	// Status is 0 (OK), 1 (WARNING), 2 (CRITICAL), or 3 (UNKNOWN)
+	Status int
+
+	// Timestamp is the timestamp field, or 0 if it wasn't given
+	Timestamp int64
+
+	Hostname string
+	Message  string
+
+	Tags        []string
+	ContainerID string
+}
+
+// Packet is everything parsed from a single datagram
+type Packet struct {
+	Metrics       []Metric
+	Events        []Event
+	ServiceChecks []ServiceCheck
+
+	// Errors has a *ParseError for each line which couldn't be parsed
+	Errors []error
+}
+
+// ParseError is returned for a line which isn't valid DogStatsD
+type ParseError struct {
+	// Kind is "metric", "event", or "service check"
+	Kind string
+
+	// Line is the whole line which couldn't be parsed
+	Line string
+
+	// Field is the part of the line which was invalid, if it was a single
+	// field
+	Field string
+
+	// Reason describes what was wrong with the line
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Kind, e.Line, e.Reason)
+}
@@ -0,0 +1,291 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package dogstatsd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// kinds are the stat kinds understood by the agent
+var kinds = map[string]bool{"c": true, "g": true, "ms": true, "h": true, "s": true, "d": true}
+
+// errUnknownField marks a field that isn't valid for the kind of line; it's
+// reported to the caller as a *ParseError
+var errUnknownField = errors.New("unknown field")
+
+// Parse parses each newline separated line of a datagram. Blank lines are
+// skipped, and lines which can't be parsed are reported in the Packet's
+// Errors without stopping the rest of the datagram from being parsed.
+func Parse(b []byte) (p Packet) {
+	for _, line := range strings.Split(string(b), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "_e{"):
+			if e, err := ParseEvent(line); err != nil {
+				p.Errors = append(p.Errors, err)
+			} else {
+				p.Events = append(p.Events, e)
+			}
+
+		case strings.HasPrefix(line, "_sc|"):
+			if sc, err := ParseServiceCheck(line); err != nil {
+				p.Errors = append(p.Errors, err)
+			} else {
+				p.ServiceChecks = append(p.ServiceChecks, sc)
+			}
+
+		default:
+			if m, err := ParseMetric(line); err != nil {
+				p.Errors = append(p.Errors, err)
+			} else {
+				p.Metrics = append(p.Metrics, m)
+			}
+		}
+	}
+
+	return
+}
+
+// ParseMetric parses a single stat:
+//
+// name:value[:value...]|kind[|@rate][|#tags][|c:id][|Ttimestamp]
+func ParseMetric(line string) (Metric, error) {
+	m := Metric{SampleRate: 1}
+
+	invalid := func(field, reason string) error {
+		return &ParseError{Kind: "metric", Line: line, Field: field, Reason: reason}
+	}
+
+	fields := strings.Split(line, "|")
+
+	if len(fields) < 2 {
+		return m, invalid("", "missing kind")
+	}
+
+	i := strings.IndexByte(fields[0], ':')
+
+	if i < 1 {
+		return m, invalid(fields[0], "missing name or value")
+	}
+
+	m.Name = fields[0][:i]
+
+	if m.Kind = fields[1]; len(m.Kind) == 0 {
+		return m, invalid("", "missing kind")
+	}
+
+	if !kinds[m.Kind] {
+		return m, invalid(m.Kind, "unknown kind "+strconv.Quote(m.Kind))
+	}
+
+	values := strings.Split(fields[0][i+1:], ":")
+
+	if m.Kind == "s" {
+		m.RawValues = values
+	}
+
+	for _, v := range values {
+		f, err := strconv.ParseFloat(v, 64)
+
+		if err != nil && m.Kind == "s" && len(v) > 0 {
+			// set members don't have to be numbers
+			m.Values = nil
+			break
+		}
+
+		if err != nil {
+			return m, invalid(v, "invalid value "+strconv.Quote(v))
+		}
+
+		m.Values = append(m.Values, f)
+	}
+
+	for _, f := range fields[2:] {
+		var err error
+
+		switch {
+		case strings.HasPrefix(f, "@"):
+			m.SampleRate, err = strconv.ParseFloat(f[1:], 64)
+
+			if err == nil && (m.SampleRate <= 0 || m.SampleRate > 1) {
+				return m, invalid(f, "sample rate must be greater than 0 and at most 1")
+			}
+		case strings.HasPrefix(f, "#"):
+			m.Tags = parseTags(f[1:])
+		case strings.HasPrefix(f, "c:"):
+			m.ContainerID = f[2:]
+		case strings.HasPrefix(f, "T"):
+			m.Timestamp, err = strconv.ParseInt(f[1:], 10, 64)
+		default:
+			err = errUnknownField
+		}
+
+		if err != nil {
+			return m, invalid(f, "invalid field "+strconv.Quote(f))
+		}
+	}
+
+	return m, nil
+}
+
+// ParseEvent parses a single event:
+//
+// _e{<title length>,<text length>}:title|text[|d:timestamp][|h:hostname]
+// [|k:aggregation key][|p:priority][|s:source type][|t:alert type][|#tags]
+// [|c:id]
+//
+// The lengths are of the title and text as sent, with their newlines escaped.
+func ParseEvent(line string) (Event, error) {
+	var e Event
+
+	invalid := func(field, reason string) error {
+		return &ParseError{Kind: "event", Line: line, Field: field, Reason: reason}
+	}
+
+	i := strings.Index(line, "}:")
+
+	if i < 3 || !strings.HasPrefix(line, "_e{") {
+		return e, invalid("", "missing lengths")
+	}
+
+	lengths := strings.SplitN(line[3:i], ",", 2)
+
+	if len(lengths) != 2 {
+		return e, invalid(line[:i+1], "missing lengths")
+	}
+
+	tl, err := strconv.Atoi(lengths[0])
+
+	if err != nil || tl < 1 {
+		return e, invalid(lengths[0], "invalid title length")
+	}
+
+	xl, err := strconv.Atoi(lengths[1])
+
+	if err != nil || xl < 1 {
+		return e, invalid(lengths[1], "invalid text length")
+	}
+
+	rest := line[i+2:]
+
+	// check each length on its own first, so huge ones can't overflow
+	if tl > len(rest) || xl > len(rest) || len(rest) < tl+1+xl || rest[tl] != '|' {
+		return e, invalid("", "lengths don't match")
+	}
+
+	e.Title = unescapeEvent(rest[:tl])
+	e.Text = unescapeEvent(rest[tl+1 : tl+1+xl])
+
+	if rest = rest[tl+1+xl:]; len(rest) == 0 {
+		return e, nil
+	}
+
+	if rest[0] != '|' {
+		return e, invalid("", "lengths don't match")
+	}
+
+	for _, f := range strings.Split(rest[1:], "|") {
+		switch {
+		case strings.HasPrefix(f, "d:"):
+			e.Timestamp, err = strconv.ParseInt(f[2:], 10, 64)
+		case strings.HasPrefix(f, "h:"):
+			e.Hostname = f[2:]
+		case strings.HasPrefix(f, "k:"):
+			e.AggregationKey = f[2:]
+		case strings.HasPrefix(f, "p:"):
+			e.Priority = f[2:]
+		case strings.HasPrefix(f, "s:"):
+			e.SourceType = f[2:]
+		case strings.HasPrefix(f, "t:"):
+			e.AlertType = f[2:]
+		case strings.HasPrefix(f, "c:"):
+			e.ContainerID = f[2:]
+		case strings.HasPrefix(f, "#"):
+			e.Tags = parseTags(f[1:])
+		default:
+			err = errUnknownField
+		}
+
+		if err != nil {
+			return e, invalid(f, "invalid field "+strconv.Quote(f))
+		}
+	}
+
+	return e, nil
+}
+
+// ParseServiceCheck parses a single service check:
+//
+// _sc|name|status[|d:timestamp][|h:hostname][|m:message][|#tags][|c:id]
+func ParseServiceCheck(line string) (ServiceCheck, error) {
+	var sc ServiceCheck
+
+	invalid := func(field, reason string) error {
+		return &ParseError{Kind: "service check", Line: line, Field: field, Reason: reason}
+	}
+
+	fields := strings.Split(line, "|")
+
+	if len(fields) < 3 || len(fields[1]) == 0 {
+		return sc, invalid("", "missing name or status")
+	}
+
+	sc.Name = fields[1]
+
+	status, err := strconv.Atoi(fields[2])
+
+	if err != nil || status < 0 || status > 3 {
+		return sc, invalid(fields[2], "invalid status "+strconv.Quote(fields[2]))
+	}
+
+	sc.Status = status
+
+	for _, f := range fields[3:] {
+		switch {
+		case strings.HasPrefix(f, "d:"):
+			sc.Timestamp, err = strconv.ParseInt(f[2:], 10, 64)
+		case strings.HasPrefix(f, "h:"):
+			sc.Hostname = f[2:]
+		case strings.HasPrefix(f, "m:"):
+			sc.Message = f[2:]
+		case strings.HasPrefix(f, "c:"):
+			sc.ContainerID = f[2:]
+		case strings.HasPrefix(f, "#"):
+			sc.Tags = parseTags(f[1:])
+		default:
+			err = errUnknownField
+		}
+
+		if err != nil {
+			return sc, invalid(f, "invalid field "+strconv.Quote(f))
+		}
+	}
+
+	return sc, nil
+}
+
+// parseTags splits comma separated tags, skipping empty ones (such as the
+// trailing comma left behind when Godspeed truncates a stat's tags)
+func parseTags(s string) []string {
+	var tags []string
+
+	for _, t := range strings.Split(s, ",") {
+		if len(t) > 0 {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// unescapeEvent reverses the newline escaping done to event titles and text
+func unescapeEvent(s string) string {
+	return strings.Replace(s, "\\n", "\n", -1)
+}
@@ -0,0 +1,28 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package dogstatsd_test
+
+import (
+	"fmt"
+
+	"github.com/PagerDuty/godspeed/dogstatsd"
+)
+
+func ExampleParse() {
+	p := dogstatsd.Parse([]byte("page.views:1|c|#page:home\nrequest.time:12:15|ms|@0.5\nbroken"))
+
+	for _, m := range p.Metrics {
+		fmt.Println(m.Name, m.Kind, m.Values, m.SampleRate, m.Tags)
+	}
+
+	for _, err := range p.Errors {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// page.views c [1] 1 [page:home]
+	// request.time ms [12 15] 0.5 []
+	// invalid metric "broken": missing kind
+}
@@ -0,0 +1,241 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package dogstatsd_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+	"github.com/PagerDuty/godspeed/dogstatsd"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ParseTestSuite struct{}
+
+var _ = Suite(&ParseTestSuite{})
+
+// packets is a godspeed.Transport which keeps everything written to it
+type packets [][]byte
+
+func (p *packets) Write(b []byte) (int, error) {
+	*p = append(*p, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (p *packets) Close() error { return nil }
+
+func (t *ParseTestSuite) TestParseMetric(c *C) {
+	m, err := dogstatsd.ParseMetric("a.b:1:2.5:3|h|@0.25|#x:y,z|c:abc123|T1657100430")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, dogstatsd.Metric{
+		Name:        "a.b",
+		Kind:        "h",
+		Values:      []float64{1, 2.5, 3},
+		SampleRate:  0.25,
+		Tags:        []string{"x:y", "z"},
+		ContainerID: "abc123",
+		Timestamp:   1657100430,
+	})
+
+	// empty tags, like those left by truncation, are skipped
+	m, err = dogstatsd.ParseMetric("a:-1|c|#test0,")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, dogstatsd.Metric{Name: "a", Kind: "c", Values: []float64{-1}, SampleRate: 1, Tags: []string{"test0"}})
+
+	// set members may be strings
+	m, err = dogstatsd.ParseMetric("users:alice:bob|s")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, dogstatsd.Metric{Name: "users", Kind: "s", RawValues: []string{"alice", "bob"}, SampleRate: 1})
+
+	m, err = dogstatsd.ParseMetric("users:42|s")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, dogstatsd.Metric{Name: "users", Kind: "s", Values: []float64{42}, RawValues: []string{"42"}, SampleRate: 1})
+
+	tests := []struct {
+		line, field, reason string
+	}{
+		{"nokind:1", "", "missing kind"},
+		{"a:1|", "", "missing kind"},
+		{"novalue|c", "novalue", "missing name or value"},
+		{":1|c", ":1", "missing name or value"},
+		{"a:x|c", "x", `invalid value "x"`},
+		{"a:1:|c", "", `invalid value ""`},
+		{"a:1:|s", "", `invalid value ""`},
+		{"a:1|q", "q", `unknown kind "q"`},
+		{"a:1|c|@2", "@2", "sample rate must be greater than 0 and at most 1"},
+		{"a:1|c|@0", "@0", "sample rate must be greater than 0 and at most 1"},
+		{"a:1|c|@x", "@x", `invalid field "@x"`},
+		{"a:1|g|Tnow", "Tnow", `invalid field "Tnow"`},
+		{"a:1|c|?", "?", `invalid field "?"`},
+	}
+
+	for _, test := range tests {
+		_, err := dogstatsd.ParseMetric(test.line)
+
+		var pe *dogstatsd.ParseError
+		c.Assert(errors.As(err, &pe), Equals, true, Commentf("%s", test.line))
+		c.Check(*pe, Equals, dogstatsd.ParseError{Kind: "metric", Line: test.line, Field: test.field, Reason: test.reason})
+	}
+
+	_, err = dogstatsd.ParseMetric("a:1|c|?")
+	c.Check(err, ErrorMatches, `invalid metric "a:1\|c\|\?": invalid field "\?"`)
+}
+
+func (t *ParseTestSuite) TestParseEvent(c *C) {
+	e, err := dogstatsd.ParseEvent("_e{5,9}:title|line\\ntwo|d:1234|h:host|k:key|p:low|s:src|t:error|#a,b|c:abc123")
+	c.Assert(err, IsNil)
+	c.Check(e, DeepEquals, dogstatsd.Event{
+		Title:          "title",
+		Text:           "line\ntwo",
+		Timestamp:      1234,
+		Hostname:       "host",
+		AggregationKey: "key",
+		Priority:       "low",
+		SourceType:     "src",
+		AlertType:      "error",
+		Tags:           []string{"a", "b"},
+		ContainerID:    "abc123",
+	})
+
+	// the text may contain pipes, as the lengths say where it ends
+	e, err = dogstatsd.ParseEvent("_e{1,3}:a|b|c")
+	c.Assert(err, IsNil)
+	c.Check(e, DeepEquals, dogstatsd.Event{Title: "a", Text: "b|c"})
+
+	tests := []struct {
+		line, reason string
+	}{
+		{"_e{1,1}a|b", "missing lengths"},
+		{"}:x", "missing lengths"},
+		{"ab}:x", "missing lengths"},
+		{"_x{1,1}:a|b", "missing lengths"},
+		{"_e{1}:a|b", "missing lengths"},
+		{"_e{x,1}:a|b", "invalid title length"},
+		{"_e{0,1}:|b", "invalid title length"},
+		{"_e{1,x}:a|b", "invalid text length"},
+		{"_e{5,1}:a|b", "lengths don't match"},
+		{"_e{1,1}:a|bc", "lengths don't match"},
+		{"_e{9223372036854775807,1}:a|b", "lengths don't match"},
+		{"_e{1,9223372036854775807}:a|b", "lengths don't match"},
+		{"_e{1,1}:a|b|d:x", `invalid field "d:x"`},
+		{"_e{1,1}:a|b|z:y", `invalid field "z:y"`},
+	}
+
+	for _, test := range tests {
+		_, err := dogstatsd.ParseEvent(test.line)
+
+		var pe *dogstatsd.ParseError
+		c.Assert(errors.As(err, &pe), Equals, true, Commentf("%s", test.line))
+		c.Check(pe.Kind, Equals, "event")
+		c.Check(pe.Line, Equals, test.line)
+		c.Check(pe.Reason, Equals, test.reason)
+	}
+}
+
+func (t *ParseTestSuite) TestParseServiceCheck(c *C) {
+	sc, err := dogstatsd.ParseServiceCheck("_sc|svc|2|d:1234|h:host|m:broken|#a|c:abc123")
+	c.Assert(err, IsNil)
+	c.Check(sc, DeepEquals, dogstatsd.ServiceCheck{
+		Name:        "svc",
+		Status:      2,
+		Timestamp:   1234,
+		Hostname:    "host",
+		Message:     "broken",
+		Tags:        []string{"a"},
+		ContainerID: "abc123",
+	})
+
+	tests := []struct {
+		line, field, reason string
+	}{
+		{"_sc|svc", "", "missing name or status"},
+		{"_sc||0", "", "missing name or status"},
+		{"_sc|svc|4", "4", `invalid status "4"`},
+		{"_sc|svc|ok", "ok", `invalid status "ok"`},
+		{"_sc|svc|0|d:x", "d:x", `invalid field "d:x"`},
+		{"_sc|svc|0|x", "x", `invalid field "x"`},
+	}
+
+	for _, test := range tests {
+		_, err := dogstatsd.ParseServiceCheck(test.line)
+
+		var pe *dogstatsd.ParseError
+		c.Assert(errors.As(err, &pe), Equals, true, Commentf("%s", test.line))
+		c.Check(*pe, Equals, dogstatsd.ParseError{Kind: "service check", Line: test.line, Field: test.field, Reason: test.reason})
+	}
+}
+
+func (t *ParseTestSuite) TestParse(c *C) {
+	p := dogstatsd.Parse([]byte("a:1|c\n\n_e{1,1}:a|b\nbad\n_sc|svc|0\n_sc|svc|9\n"))
+
+	c.Check(p.Metrics, DeepEquals, []dogstatsd.Metric{{Name: "a", Kind: "c", Values: []float64{1}, SampleRate: 1}})
+	c.Check(p.Events, DeepEquals, []dogstatsd.Event{{Title: "a", Text: "b"}})
+	c.Check(p.ServiceChecks, DeepEquals, []dogstatsd.ServiceCheck{{Name: "svc"}})
+
+	c.Assert(len(p.Errors), Equals, 2)
+	c.Check(p.Errors[0], ErrorMatches, `invalid metric "bad": missing kind`)
+	c.Check(p.Errors[1], ErrorMatches, `invalid service check "_sc\|svc\|9": invalid status "9"`)
+
+	c.Check(dogstatsd.Parse(nil), DeepEquals, dogstatsd.Packet{})
+}
+
+func (t *ParseTestSuite) TestParseGodspeed(c *C) {
+	//
+	// test that everything Godspeed emits can be parsed back
+	//
+	var p packets
+
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(&p),
+		godspeed.WithNamespace("ns"),
+		godspeed.WithGlobalTags("env:test"),
+		godspeed.WithBuffering(8192),
+	)
+	c.Assert(err, IsNil)
+
+	g.ContainerID = "abc123"
+
+	c.Assert(g.Count("test.count", 3, []string{"a"}), IsNil)
+	c.Assert(g.Send("test.timing", "ms", 2054.5, 1, nil), IsNil)
+	c.Assert(g.SendWithTimestamp("test.gauge", "g", 42, time.Unix(1657100430, 0), nil), IsNil)
+	c.Assert(g.Event("deploy\nfinished", "it|worked", map[string]string{"alert_type": "success", "date_happened": "1234"}, nil), IsNil)
+	c.Assert(g.ServiceCheck("svc", 1, map[string]string{"service_check_message": "slow", "hostname": "web1"}, []string{"b"}), IsNil)
+	c.Assert(g.Close(), IsNil)
+
+	c.Assert(len(p), Equals, 1)
+
+	packet := dogstatsd.Parse(p[0])
+	c.Check(packet.Errors, IsNil)
+
+	c.Check(packet.Metrics, DeepEquals, []dogstatsd.Metric{
+		{Name: "ns.test.count", Kind: "c", Values: []float64{3}, SampleRate: 1, Tags: []string{"env:test", "a"}, ContainerID: "abc123"},
+		{Name: "ns.test.timing", Kind: "ms", Values: []float64{2054.5}, SampleRate: 1, Tags: []string{"env:test"}, ContainerID: "abc123"},
+		{Name: "ns.test.gauge", Kind: "g", Values: []float64{42}, SampleRate: 1, Tags: []string{"env:test"}, ContainerID: "abc123", Timestamp: 1657100430},
+	})
+
+	c.Check(packet.Events, DeepEquals, []dogstatsd.Event{{
+		Title:       "deploy\nfinished",
+		Text:        "it|worked",
+		Timestamp:   1234,
+		AlertType:   "success",
+		Tags:        []string{"env:test"},
+		ContainerID: "abc123",
+	}})
+
+	c.Check(packet.ServiceChecks, DeepEquals, []dogstatsd.ServiceCheck{{
+		Name:        "svc",
+		Status:      1,
+		Hostname:    "web1",
+		Message:     "slow",
+		Tags:        []string{"env:test", "b"},
+		ContainerID: "abc123",
+	}})
+}
@@ -0,0 +1,202 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	envAgentHost     = "DD_AGENT_HOST"
+	envDogStatsDPort = "DD_DOGSTATSD_PORT"
+	envDogStatsDURL  = "DD_DOGSTATSD_URL"
+	envEnv           = "DD_ENV"
+	envService       = "DD_SERVICE"
+	envVersion       = "DD_VERSION"
+	envTags          = "DD_TAGS"
+	envDisable       = "DD_DOGSTATSD_DISABLE"
+)
+
+// EnvTags returns the tags for Datadog's unified service tagging, built from
+// the DD_ENV, DD_SERVICE, and DD_VERSION environment variables (as env:,
+// service:, and version: tags), followed by the tags in DD_TAGS. DD_TAGS may
+// be separated by commas or spaces. Variables that aren't set are skipped.
+func EnvTags() []string {
+	var tags []string
+
+	for _, v := range []struct{ key, prefix string }{
+		{envEnv, "env:"},
+		{envService, "service:"},
+		{envVersion, "version:"},
+	} {
+		if val := strings.TrimSpace(os.Getenv(v.key)); len(val) > 0 {
+			tags = append(tags, v.prefix+val)
+		}
+	}
+
+	tags = append(tags, strings.FieldsFunc(os.Getenv(envTags), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})...)
+
+	return mergeTags(tags, nil)
+}
+
+// agentAddress works out where the agent is listening, using the same
+// environment variables as the official Datadog clients. DD_DOGSTATSD_URL
+// (udp://host:port or unix:///path) wins, followed by DD_AGENT_HOST and
+// DD_DOGSTATSD_PORT, falling back to DefaultHost and DefaultPort. The network
+// returned is either "udp" or "unixgram".
+func agentAddress() (network, host string, port int, err error) {
+	if u := os.Getenv(envDogStatsDURL); len(u) > 0 {
+		return parseAgentURL(u)
+	}
+
+	host, port = DefaultHost, DefaultPort
+
+	if h := os.Getenv(envAgentHost); len(h) > 0 {
+		// the official clients allow a socket path to be given here too
+		if strings.HasPrefix(h, "unix://") {
+			return parseAgentURL(h)
+		}
+
+		host = h
+	}
+
+	if p := os.Getenv(envDogStatsDPort); len(p) > 0 {
+		if port, err = parsePort(p); err != nil {
+			return "", "", 0, fmt.Errorf("invalid %s %q: %v", envDogStatsDPort, p, err)
+		}
+	}
+
+	return "udp", host, port, nil
+}
+
+// parseAgentURL parses a udp:// or unix:// DogStatsD URL
+func parseAgentURL(s string) (network, host string, port int, err error) {
+	u, err := url.Parse(s)
+
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid %s %q: %v", envDogStatsDURL, s, err)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		if len(u.Hostname()) == 0 {
+			return "", "", 0, fmt.Errorf("invalid %s %q: missing host", envDogStatsDURL, s)
+		}
+
+		if len(u.Port()) == 0 {
+			return "udp", u.Hostname(), DefaultPort, nil
+		}
+
+		if port, err = parsePort(u.Port()); err != nil {
+			return "", "", 0, fmt.Errorf("invalid %s %q: %v", envDogStatsDURL, s, err)
+		}
+
+		return "udp", u.Hostname(), port, nil
+
+	case "unix", "unixgram":
+		if len(u.Path) == 0 {
+			return "", "", 0, fmt.Errorf("invalid %s %q: missing socket path", envDogStatsDURL, s)
+		}
+
+		return "unixgram", u.Path, 0, nil
+
+	default:
+		return "", "", 0, fmt.Errorf("invalid %s %q: unsupported scheme %q; must be udp or unix", envDogStatsDURL, s, u.Scheme)
+	}
+}
+
+// envDisabled returns whether DD_DOGSTATSD_DISABLE turns stats off; it's
+// false when the variable isn't set
+func envDisabled() (bool, error) {
+	v := strings.TrimSpace(os.Getenv(envDisable))
+
+	if len(v) == 0 {
+		return false, nil
+	}
+
+	disabled, err := strconv.ParseBool(v)
+
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", envDisable, v)
+	}
+
+	return disabled, nil
+}
+
+// parsePort parses a port number, making sure it's in range
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port must be a number between 1 and 65535")
+	}
+
+	return port, nil
+}
+
+// NewFromEnv is like NewDefault() except it finds the agent using the same
+// environment variables as the official Datadog clients:
+//
+// DD_DOGSTATSD_URL is used if set, and can be either udp://<host>:<port> or
+// unix:///path/to/dsd.socket. Otherwise DD_AGENT_HOST and DD_DOGSTATSD_PORT
+// are used, with DefaultHost and DefaultPort used for either one that isn't
+// set. An error is returned if any of them are malformed.
+//
+// The tags from EnvTags() are added to the instance, so everything emitted is
+// tagged with the env, service, and version. Origin detection is enabled too
+// (see EnableOriginDetection()), unless DD_ORIGIN_DETECTION_ENABLED is false.
+//
+// Any options are applied on top of what's found in the environment, so
+// WithEnvTags(false) or WithOriginDetection(false) turn those off, and
+// WithAddress() overrides where the agent is.
+func NewFromEnv(opts ...Option) (g *Godspeed, err error) {
+	envOpts, err := envOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	g, err = NewClient(append(envOpts, opts...)...)
+	return
+}
+
+// NewAsyncFromEnv is just like NewAsync except it finds the agent the same
+// way as NewFromEnv(), and takes the same options as NewAsyncClient()
+func NewAsyncFromEnv(opts ...Option) (a *AsyncGodspeed, err error) {
+	envOpts, err := envOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = NewAsyncClient(append(envOpts, opts...)...)
+	return
+}
+
+// envOptions returns the options NewFromEnv() starts from
+func envOptions() ([]Option, error) {
+	network, host, port, err := agentAddress()
+
+	if err != nil {
+		return nil, err
+	}
+
+	address := withLegacyAddress(host, port)
+
+	if network == "unixgram" {
+		address = WithUnixSocket(host)
+	}
+
+	od, err := strconv.ParseBool(os.Getenv(envOriginDetection))
+
+	return []Option{address, WithEnvTags(true), WithOriginDetection(err != nil || od)}, nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/PagerDuty/godspeed"
+	"github.com/PagerDuty/godspeed/gspdtest"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+var envVars = []string{
+	"DD_AGENT_HOST", "DD_DOGSTATSD_PORT", "DD_DOGSTATSD_URL",
+	"DD_ENV", "DD_SERVICE", "DD_VERSION", "DD_TAGS",
+	"DD_ENTITY_ID", "DD_ORIGIN_DETECTION_ENABLED", "DD_DOGSTATSD_DISABLE",
+}
+
+type EnvTestSuite struct {
+	saved map[string]string
+}
+
+var _ = Suite(&EnvTestSuite{})
+
+func (t *EnvTestSuite) SetUpTest(c *C) {
+	t.saved = make(map[string]string)
+
+	for _, k := range envVars {
+		if v, ok := os.LookupEnv(k); ok {
+			t.saved[k] = v
+		}
+
+		os.Unsetenv(k)
+	}
+}
+
+func (t *EnvTestSuite) TearDownTest(c *C) {
+	for _, k := range envVars {
+		os.Unsetenv(k)
+
+		if v, ok := t.saved[k]; ok {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func (t *EnvTestSuite) TestNewFromEnvDefault(c *C) {
+	g, err := godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Assert(g.Conn, NotNil)
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.1:8125")
+}
+
+func (t *EnvTestSuite) TestNewFromEnvHostPort(c *C) {
+	os.Setenv("DD_AGENT_HOST", "127.0.0.2")
+	os.Setenv("DD_DOGSTATSD_PORT", "9125")
+
+	g, err := godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.2:9125")
+
+	// a port on its own should use the default host
+	os.Unsetenv("DD_AGENT_HOST")
+
+	g, err = godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.1:9125")
+}
+
+func (t *EnvTestSuite) TestNewFromEnvURL(c *C) {
+	// the URL should win over the host and port
+	os.Setenv("DD_AGENT_HOST", "127.0.0.2")
+	os.Setenv("DD_DOGSTATSD_PORT", "9125")
+	os.Setenv("DD_DOGSTATSD_URL", "udp://127.0.0.3:9126")
+
+	g, err := godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.3:9126")
+
+	os.Setenv("DD_DOGSTATSD_URL", "udp://127.0.0.3")
+
+	g, err = godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.3:8125")
+}
+
+func (t *EnvTestSuite) TestNewFromEnvIPv6(c *C) {
+	os.Setenv("DD_AGENT_HOST", "::1")
+
+	g, err := godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "[::1]:8125")
+
+	os.Setenv("DD_DOGSTATSD_URL", "udp://[::1]:9126")
+
+	g, err = godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "[::1]:9126")
+}
+
+func (t *EnvTestSuite) TestNewFromEnvUnix(c *C) {
+	path := filepath.Join(c.MkDir(), "dsd.socket")
+
+	l, ctrl, out := gspdtest.BuildUnixListener(path)
+	go gspdtest.Listener(l, ctrl, out)
+
+	defer close(ctrl)
+	defer l.Close()
+
+	for _, v := range []string{"DD_DOGSTATSD_URL", "DD_AGENT_HOST"} {
+		os.Setenv(v, "unix://"+path)
+
+		a, err := godspeed.NewAsyncFromEnv()
+		c.Assert(err, IsNil)
+		c.Check(a.Godspeed.Conn, IsNil)
+
+		a.W.Add(1)
+		a.Incr("test.incr", nil, a.W)
+
+		dgram, ok := <-out
+		c.Assert(ok, Equals, true)
+		c.Check(string(dgram), Equals, "test.incr:1|c")
+
+		a.Godspeed.Close()
+		os.Unsetenv(v)
+	}
+}
+
+func (t *EnvTestSuite) TestNewFromEnvErrors(c *C) {
+	tests := []struct {
+		key, value, err string
+	}{
+		{"DD_DOGSTATSD_PORT", "abc", `invalid DD_DOGSTATSD_PORT "abc": port must be a number between 1 and 65535`},
+		{"DD_DOGSTATSD_PORT", "70000", `invalid DD_DOGSTATSD_PORT "70000": port must be a number between 1 and 65535`},
+		{"DD_DOGSTATSD_URL", "tcp://127.0.0.1:8125", `invalid DD_DOGSTATSD_URL "tcp://127.0.0.1:8125": unsupported scheme "tcp"; must be udp or unix`},
+		{"DD_DOGSTATSD_URL", "127.0.0.1:8125", `invalid DD_DOGSTATSD_URL "127.0.0.1:8125": .*`},
+		{"DD_DOGSTATSD_URL", "udp://:8125", `invalid DD_DOGSTATSD_URL "udp://:8125": missing host`},
+		{"DD_DOGSTATSD_URL", "udp://127.0.0.1:0", `invalid DD_DOGSTATSD_URL "udp://127.0.0.1:0": port must be a number between 1 and 65535`},
+		{"DD_DOGSTATSD_URL", "unix://", `invalid DD_DOGSTATSD_URL "unix://": missing socket path`},
+	}
+
+	for _, tt := range tests {
+		os.Setenv(tt.key, tt.value)
+
+		g, err := godspeed.NewFromEnv()
+		c.Check(g, IsNil)
+		c.Check(err, ErrorMatches, tt.err)
+
+		os.Unsetenv(tt.key)
+	}
+}
+
+func (t *EnvTestSuite) TestEnvTags(c *C) {
+	c.Check(godspeed.EnvTags(), IsNil)
+
+	os.Setenv("DD_ENV", "production")
+	os.Setenv("DD_SERVICE", "api")
+	os.Setenv("DD_VERSION", " 1.2.3 ")
+	os.Setenv("DD_TAGS", "team:core,region:us-west-2 zone:a,, env:production")
+
+	c.Check(godspeed.EnvTags(), DeepEquals, []string{
+		"env:production", "service:api", "version:1.2.3", "team:core", "region:us-west-2", "zone:a",
+	})
+
+	os.Unsetenv("DD_ENV")
+	os.Unsetenv("DD_TAGS")
+
+	c.Check(godspeed.EnvTags(), DeepEquals, []string{"service:api", "version:1.2.3"})
+}
+
+func (t *EnvTestSuite) TestNewFromEnvTags(c *C) {
+	os.Setenv("DD_ENV", "production")
+	os.Setenv("DD_SERVICE", "api")
+	os.Setenv("DD_VERSION", "1.2.3")
+
+	// don't let the container the tests are running in change the output
+	os.Setenv("DD_ORIGIN_DETECTION_ENABLED", "false")
+
+	l, ctrl, out := gspdtest.BuildListener(8126)
+	go gspdtest.Listener(l, ctrl, out)
+
+	defer close(ctrl)
+	defer l.Close()
+
+	os.Setenv("DD_DOGSTATSD_PORT", "8126")
+
+	g, err := godspeed.NewFromEnv()
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Tags, DeepEquals, []string{"env:production", "service:api", "version:1.2.3"})
+
+	//
+	// test that the tags are sent with stats, events, and service checks
+	//
+	c.Assert(g.Incr("test.incr", []string{"tag:a"}), IsNil)
+
+	dgram, ok := <-out
+	c.Assert(ok, Equals, true)
+	c.Check(string(dgram), Equals, "test.incr:1|c|#env:production,service:api,version:1.2.3,tag:a")
+
+	c.Assert(g.Event("a", "b", nil, nil), IsNil)
+
+	dgram, ok = <-out
+	c.Assert(ok, Equals, true)
+	c.Check(string(dgram), Equals, "_e{1,1}:a|b|#env:production,service:api,version:1.2.3")
+
+	c.Assert(g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+
+	dgram, ok = <-out
+	c.Assert(ok, Equals, true)
+	c.Check(string(dgram), Equals, "_sc|testSvc|0|#env:production,service:api,version:1.2.3")
+}
+
+func (t *EnvTestSuite) TestNewClientOrNoopEnv(c *C) {
+	transport := godspeed.WithTransport(&memTransport{})
+
+	// not set, so stats are emitted
+	cl, err := godspeed.NewClientOrNoop(transport)
+	c.Assert(err, IsNil)
+	_, ok := cl.(*godspeed.Godspeed)
+	c.Check(ok, Equals, true)
+
+	for _, v := range []string{"true", "1", " TRUE "} {
+		os.Setenv("DD_DOGSTATSD_DISABLE", v)
+
+		cl, err = godspeed.NewClientOrNoop(transport)
+		c.Assert(err, IsNil)
+		_, ok = cl.(*godspeed.Noop)
+		c.Check(ok, Equals, true, Commentf("%q", v))
+	}
+
+	// the option wins over the environment
+	cl, err = godspeed.NewClientOrNoop(transport, godspeed.WithDisabled(false))
+	c.Assert(err, IsNil)
+	_, ok = cl.(*godspeed.Godspeed)
+	c.Check(ok, Equals, true)
+
+	os.Setenv("DD_DOGSTATSD_DISABLE", "false")
+
+	cl, err = godspeed.NewClientOrNoop(transport)
+	c.Assert(err, IsNil)
+	_, ok = cl.(*godspeed.Godspeed)
+	c.Check(ok, Equals, true)
+
+	cl, err = godspeed.NewClientOrNoop(transport, godspeed.WithDisabled(true))
+	c.Assert(err, IsNil)
+	_, ok = cl.(*godspeed.Noop)
+	c.Check(ok, Equals, true)
+
+	os.Setenv("DD_DOGSTATSD_DISABLE", "nope")
+
+	cl, err = godspeed.NewClientOrNoop(transport)
+	c.Check(cl, IsNil)
+	c.Check(err, ErrorMatches, `invalid DD_DOGSTATSD_DISABLE "nope": must be true or false`)
+}
+
+func (t *EnvTestSuite) TestEnvOptions(c *C) {
+	os.Setenv("DD_ENV", "production")
+	os.Setenv("DD_SERVICE", "api")
+	os.Setenv("DD_ENTITY_ID", "3d274242-8ee0-11e9-a8a6-1e68d864ef1a")
+
+	//
+	// test that NewClient() can opt into them
+	//
+	m := &memTransport{}
+
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(m),
+		godspeed.WithGlobalTags("tag:a"),
+		godspeed.WithEnvTags(true),
+		godspeed.WithOriginDetection(true),
+	)
+	c.Assert(err, IsNil)
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Check(m.Writes(), DeepEquals, []string{
+		"test.incr:1|c|#env:production,service:api,tag:a,dd.internal.entity_id:3d274242-8ee0-11e9-a8a6-1e68d864ef1a",
+	})
+
+	//
+	// test that NewFromEnv() can opt out of them
+	//
+	g, err = godspeed.NewFromEnv(godspeed.WithEnvTags(false), godspeed.WithOriginDetection(false))
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Tags, DeepEquals, []string{})
+	c.Check(g.ContainerID, Equals, "")
+}
@@ -0,0 +1,117 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotConnected is returned when emitting using a Godspeed instance
+	// that has no Transport or Conn
+	ErrNotConnected = errors.New("socket not created")
+
+	// ErrClosed is returned when emitting using a Godspeed instance that has
+	// been closed, and is the Err of the *AsyncError for emissions to a
+	// closed AsyncGodspeed instance
+	ErrClosed = errors.New("client is closed")
+
+	// ErrQueueFull is the Err of the *AsyncError for emissions dropped
+	// because the AsyncGodspeed queue was full
+	ErrQueueFull = errors.New("async queue is full")
+
+	// ErrPacketTooLarge matches, using errors.Is(), any *PacketTooLargeError
+	ErrPacketTooLarge = errors.New("packet too large")
+
+	// ErrInvalidName matches, using errors.Is(), any *InvalidNameError
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrInvalidStatus matches, using errors.Is(), any *InvalidStatusError
+	ErrInvalidStatus = errors.New("invalid service check status")
+
+	// ErrDisabled is returned by NewClient() and NewAsyncClient() when
+	// given WithDisabled(true); use NewClientOrNoop() instead
+	ErrDisabled = errors.New("client is disabled")
+
+	// ErrEmptyBody is returned when sending an event without a body
+	ErrEmptyBody = errors.New("body must have at least one character")
+)
+
+// PacketTooLargeError is returned when an emission is larger than the packet
+// limit, and can't be truncated
+type PacketTooLargeError struct {
+	// Kind is "stat", "event", or "service_check"
+	Kind string
+
+	// Name is the stat name (without the namespace), event title, or
+	// service check name
+	Name string
+
+	// Size is the size of the emission in bytes
+	Size int
+
+	// Limit is the largest emission allowed, in bytes
+	Limit int
+}
+
+func (e *PacketTooLargeError) Error() string {
+	if e.Kind == "service_check" {
+		return fmt.Sprintf("error sending %s service check, packet larger than %d (%d)", e.Name, e.Limit, e.Size)
+	}
+
+	return fmt.Sprintf("error sending %v, packet larger than %d (%d)", e.Name, e.Limit, e.Size)
+}
+
+// Is returns whether target is ErrPacketTooLarge
+func (e *PacketTooLargeError) Is(target error) bool {
+	return target == ErrPacketTooLarge
+}
+
+// InvalidNameError is returned when an event title or service check name
+// can't be sent
+type InvalidNameError struct {
+	// Kind is "event" or "service_check"
+	Kind string
+
+	// Name is the invalid title or name
+	Name string
+}
+
+func (e *InvalidNameError) Error() string {
+	if e.Kind == "event" {
+		return "title must have at least one character"
+	}
+
+	if len(e.Name) == 0 {
+		return "service name must have at least one character"
+	}
+
+	return fmt.Sprintf("service name '%s' may not include pipe character ('|')", e.Name)
+}
+
+// Is returns whether target is ErrInvalidName
+func (e *InvalidNameError) Is(target error) bool {
+	return target == ErrInvalidName
+}
+
+// InvalidStatusError is returned when a service check status isn't one of
+// 0 (OK), 1 (WARNING), 2 (CRITICAL), or 3 (UNKNOWN)
+type InvalidStatusError struct {
+	// Name is the service check name
+	Name string
+
+	// Status is the invalid status
+	Status int
+}
+
+func (e *InvalidStatusError) Error() string {
+	return fmt.Sprintf("unknown service status (%d); known values: 0,1,2,3", e.Status)
+}
+
+// Is returns whether target is ErrInvalidStatus
+func (e *InvalidStatusError) Is(target error) bool {
+	return target == ErrInvalidStatus
+}
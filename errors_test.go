@@ -0,0 +1,120 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type ErrorsTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&ErrorsTestSuite{})
+
+func (t *ErrorsTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+	t.g = godspeed.NewWithTransport(t.m, false)
+}
+
+func (t *ErrorsTestSuite) TestPacketTooLarge(c *C) {
+	long := strings.Repeat("a", godspeed.MaxBytes)
+
+	var ptl *godspeed.PacketTooLargeError
+
+	err := t.g.Gauge(long, 1, nil)
+	c.Check(errors.Is(err, godspeed.ErrPacketTooLarge), Equals, true)
+	c.Assert(errors.As(err, &ptl), Equals, true)
+	c.Check(ptl.Kind, Equals, "stat")
+	c.Check(ptl.Name, Equals, long)
+	c.Check(ptl.Size, Equals, godspeed.MaxBytes+4)
+	c.Check(ptl.Limit, Equals, godspeed.MaxBytes)
+	c.Check(err, ErrorMatches, "error sending a+, packet larger than 8192 \\(8196\\)")
+
+	err = t.g.Event(long, "b", nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrPacketTooLarge), Equals, true)
+	c.Assert(errors.As(err, &ptl), Equals, true)
+	c.Check(ptl.Kind, Equals, "event")
+	c.Check(ptl.Size, Equals, godspeed.MaxBytes+13)
+	c.Check(err, ErrorMatches, "error sending a+, packet larger than 8192 \\(8205\\)")
+
+	err = t.g.ServiceCheck(long, 0, nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrPacketTooLarge), Equals, true)
+	c.Assert(errors.As(err, &ptl), Equals, true)
+	c.Check(ptl.Kind, Equals, "service_check")
+	c.Check(ptl.Size, Equals, godspeed.MaxBytes+6)
+	c.Check(err, ErrorMatches, "error sending a+ service check, packet larger than 8192 \\(8198\\)")
+
+	c.Check(len(t.m.Writes()), Equals, 0)
+}
+
+func (t *ErrorsTestSuite) TestInvalidName(c *C) {
+	var ine *godspeed.InvalidNameError
+
+	err := t.g.Event("", "b", nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrInvalidName), Equals, true)
+	c.Assert(errors.As(err, &ine), Equals, true)
+	c.Check(ine.Kind, Equals, "event")
+	c.Check(err, ErrorMatches, "title must have at least one character")
+
+	err = t.g.ServiceCheck("", 0, nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrInvalidName), Equals, true)
+	c.Assert(errors.As(err, &ine), Equals, true)
+	c.Check(ine.Kind, Equals, "service_check")
+	c.Check(err, ErrorMatches, "service name must have at least one character")
+
+	err = t.g.ServiceCheck("test|svc", 0, nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrInvalidName), Equals, true)
+	c.Assert(errors.As(err, &ine), Equals, true)
+	c.Check(ine.Name, Equals, "test|svc")
+	c.Check(err, ErrorMatches, "service name 'test\\|svc' may not include pipe character \\('\\|'\\)")
+
+	err = t.g.Event("a", "", nil, nil)
+	c.Check(err, Equals, godspeed.ErrEmptyBody)
+	c.Check(errors.Is(err, godspeed.ErrInvalidName), Equals, false)
+}
+
+func (t *ErrorsTestSuite) TestInvalidStatus(c *C) {
+	var ise *godspeed.InvalidStatusError
+
+	err := t.g.ServiceCheck("testSvc", 4, nil, nil)
+	c.Check(errors.Is(err, godspeed.ErrInvalidStatus), Equals, true)
+	c.Check(errors.Is(err, godspeed.ErrInvalidName), Equals, false)
+	c.Assert(errors.As(err, &ise), Equals, true)
+	c.Check(ise.Name, Equals, "testSvc")
+	c.Check(ise.Status, Equals, 4)
+	c.Check(err, ErrorMatches, "unknown service status \\(4\\); known values: 0,1,2,3")
+}
+
+func (t *ErrorsTestSuite) TestNotConnected(c *C) {
+	g := &godspeed.Godspeed{}
+
+	c.Check(g.Incr("test.incr", nil), Equals, godspeed.ErrNotConnected)
+	c.Check(g.Event("a", "b", nil, nil), Equals, godspeed.ErrNotConnected)
+	c.Check(g.ServiceCheck("testSvc", 0, nil, nil), Equals, godspeed.ErrNotConnected)
+	c.Check(godspeed.ErrNotConnected, ErrorMatches, "socket not created")
+}
+
+func (t *ErrorsTestSuite) TestClosed(c *C) {
+	d := t.g.WithTags("derived")
+
+	c.Assert(t.g.Close(), IsNil)
+
+	c.Check(t.g.Incr("test.incr", nil), Equals, godspeed.ErrClosed)
+	c.Check(t.g.Event("a", "b", nil, nil), Equals, godspeed.ErrClosed)
+	c.Check(t.g.ServiceCheck("testSvc", 0, nil, nil), Equals, godspeed.ErrClosed)
+
+	// instances derived from a closed instance are closed too
+	c.Check(d.Incr("test.incr", nil), Equals, godspeed.ErrClosed)
+
+	c.Check(len(t.m.Writes()), Equals, 0)
+}
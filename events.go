@@ -27,11 +27,15 @@ func removePipes(s string) string {
 // field can be used to send the optional keys.
 func (g *Godspeed) Event(title, text string, fields map[string]string, tags []string) error {
 	if len(title) < 1 {
-		return fmt.Errorf("title must have at least one character")
+		return &InvalidNameError{Kind: "event", Name: title}
 	}
 
 	if len(text) < 1 {
-		return fmt.Errorf("body must have at least one character")
+		return ErrEmptyBody
+	}
+
+	if err := g.ready(); err != nil {
+		return err
 	}
 
 	var buf bytes.Buffer
@@ -51,23 +55,32 @@ func (g *Godspeed) Event(title, text string, fields map[string]string, tags []st
 		}
 	}
 
-	tags = uniqueTags(append(g.Tags, tags...))
+	// the merged tags are a new slice, so they can be cleaned up in place
+	tags = mergeTags(g.globalTags(), tags)
 
 	if len(tags) > 0 {
 		for i, v := range tags {
-			tags[i] = strings.Replace(v, "|", "", -1)
+			tags[i] = removePipes(v)
 		}
 
 		buf.WriteString(fmt.Sprintf("|#%v", strings.Join(tags, ",")))
 	}
 
+	g.writeContainerID(&buf)
+
 	// this handles the logic for truncation
 	// if the buffer length is larger than the max, return an error
 	// else just write it
-	if bufLen := buf.Len(); bufLen > MaxBytes {
-		return fmt.Errorf("error sending %v, packet larger than %d (%d)", string(title), MaxBytes, buf.Len())
+	if limit := g.packetLimit(); buf.Len() > limit {
+		g.telemetry.add(counterTooLarge, 1)
+		return &PacketTooLargeError{Kind: "event", Name: title, Size: buf.Len(), Limit: limit}
+	}
+
+	_, err := g.write(buf.Bytes())
+
+	if err == nil {
+		g.telemetry.add(counterEvents, 1)
 	}
 
-	_, err := g.Conn.Write(buf.Bytes())
 	return err
 }
@@ -0,0 +1,22 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import "net"
+
+// ReadContainerID exposes readContainerID for testing against fixture files
+var ReadContainerID = readContainerID
+
+// MergeTags exposes mergeTags for testing
+var MergeTags = mergeTags
+
+// SetResolveUDPAddr replaces the lookup used by ResolvingUDPTransport,
+// returning a function which puts the original back
+func SetResolveUDPAddr(fn func(network, address string) (*net.UDPAddr, error)) (restore func()) {
+	orig := resolveUDPAddr
+	resolveUDPAddr = fn
+
+	return func() { resolveUDPAddr = orig }
+}
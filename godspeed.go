@@ -16,8 +16,10 @@
 package godspeed
 
 import (
-	"fmt"
+	"math/rand"
 	"net"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -29,15 +31,31 @@ const (
 
 	// MaxBytes is the largest UDP datagram we will try to send
 	MaxBytes = 8192
+
+	// MaxUnixBytes is the largest unix domain socket datagram we will try to
+	// send. The agent only reads up to its dogstatsd_buffer_size, so that
+	// setting needs to be raised for the extra room to be useful.
+	MaxUnixBytes = 65536
 )
 
 // Godspeed is an unbuffered Statsd client with compatibility geared towards the Datadog statsd format
-// It consists of Conn (*net.UDPConn) object for sending metrics over UDP,
+// It consists of a Transport for sending metrics (UDP by default),
 // Namespace (string) for namespacing metrics, and Tags ([]string) for tags to send with stats
+//
+// Godspeed is safe for concurrent use, as long as the Namespace and Tags are
+// changed using SetNamespace(), AddTag(), and AddTags() rather than by
+// modifying the fields directly.
 type Godspeed struct {
-	// Conn is the UDP connection used for sending the statsd emissions
+	// Conn is the UDP connection used for sending the statsd emissions. It's
+	// only set when the Transport is a *net.UDPConn, and is kept around for
+	// code written before Transport existed. If Transport is nil, emissions
+	// are written directly to Conn.
 	Conn *net.UDPConn
 
+	// Transport is what all emissions are written through. Each call to its
+	// Write method is given a single datagram.
+	Transport Transport
+
 	// Namespace is the namespace all stats emissions are prefixed with:
 	// <namespace>.<statname>
 	Namespace string
@@ -51,6 +69,49 @@ type Godspeed struct {
 	// due to the body being truncated. Meant for when a single emission would
 	// be greater than 8192 bytes.
 	AutoTruncate bool
+
+	// ContainerID is the ID of the container this process is running in. If
+	// set, it's included with each emission so that the agent can tag it
+	// with the container's tags (origin detection). See DetectContainerID()
+	// and EnableOriginDetection().
+	ContainerID string
+
+	// mu protects Namespace, Tags, ContainerID, and the error handler from
+	// being changed while they're being read by emissions on other goroutines
+	mu sync.RWMutex
+
+	// maxBytes is the largest datagram this instance will emit
+	maxBytes int
+
+	// buffer packs emissions in to larger datagrams; nil unless
+	// EnableBuffering() has been called
+	buffer *packetBuffer
+
+	// aggregator holds aggregated stats until they are flushed; nil unless
+	// EnableAggregation() has been called
+	aggregator *aggregator
+
+	// errorHandler holds the function called with errors hit in the
+	// background, and is shared with derived instances; see
+	// WithErrorHandler()
+	errorHandler *errorHandler
+
+	// random decides whether sampled stats are emitted; rand.Float64 is
+	// used if nil. See WithSampleRateSource().
+	random func() float64
+
+	// telemetry counts what's been emitted, and is shared with derived
+	// instances; nil if the instance wasn't built using one of the
+	// constructors
+	telemetry *telemetry
+
+	// closed is set to 1 by Close(), and is shared with derived instances;
+	// nil if the instance wasn't built using one of the constructors
+	closed *int32
+
+	// derived is whether this instance was made using WithTags() or
+	// WithNamespace(), in which case it doesn't own the Transport
+	derived bool
 }
 
 // New returns a new instance of a Godspeed statsd client.
@@ -59,68 +120,314 @@ type Godspeed struct {
 // autoTruncate can be used to truncate the message instead of erroring. This doesn't work
 // on events and will always return an error.
 func New(host string, port int, autoTruncate bool) (g *Godspeed, err error) {
-	// build a new UDP dialer
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, port))
-	if err != nil {
-		return nil, err
-	}
-
-	c, err := net.DialUDP("udp", nil, addr)
+	g, err = NewClient(withLegacyAddress(host, port), WithAutoTruncate(autoTruncate))
+	return
+}
 
-	// if it failed return a pointer to an empty Godspeed struct, and the error
-	if err != nil {
-		return nil, err
-	}
+// NewUnix is like New() except it emits over the unix domain datagram socket
+// at path, such as the /var/run/datadog/dsd.socket exposed by the agent.
+// Datagrams can be up to MaxUnixBytes in size. The Conn field is nil for
+// these instances, so use Close() to clean up.
+func NewUnix(path string, autoTruncate bool) (g *Godspeed, err error) {
+	g, err = NewClient(WithUnixSocket(path), WithAutoTruncate(autoTruncate))
+	return
+}
 
-	// build a new Godspeed struct with the UDPConn
-	g = &Godspeed{
-		Conn:         c,
+// NewWithTransport returns a new instance of a Godspeed statsd client which
+// writes all emissions through t. If t is a *net.UDPConn the Conn field is
+// set too, and if t is a *net.UnixConn or *StreamTransport datagrams can be
+// up to MaxUnixBytes.
+func NewWithTransport(t Transport, autoTruncate bool) *Godspeed {
+	g := &Godspeed{
+		Transport:    t,
 		Tags:         make([]string, 0),
 		AutoTruncate: autoTruncate,
+		maxBytes:     MaxBytes,
+		errorHandler: &errorHandler{},
+		telemetry:    newTelemetry(),
+		closed:       new(int32),
 	}
 
-	return
+	switch c := t.(type) {
+	case *net.UDPConn:
+		g.Conn = c
+	case *net.UnixConn, *StreamTransport:
+		g.maxBytes = MaxUnixBytes
+	}
+
+	return g
 }
 
 // NewDefault is the same as New() except it uses DefaultHost and DefaultPort for the connection.
 func NewDefault() (g *Godspeed, err error) {
-	g, err = New(DefaultHost, DefaultPort, false)
+	g, err = NewClient()
 	return
 }
 
-// AddTag allows you to add a tag for all future emitted stats.
-// It takes the tag as a string, and returns a []string containing all Godspeed tags
-func (g *Godspeed) AddTag(tag string) []string {
-	// return early if the tag already exists
-	for _, v := range g.Tags {
-		if tag == v {
-			return g.Tags
+// Flush emits any aggregated stats and writes any buffered emissions. It's a
+// no-op when neither aggregation nor buffering are enabled.
+func (g *Godspeed) Flush() (err error) {
+	if g.aggregator != nil {
+		err = g.aggregator.flush(g)
+	}
+
+	if g.buffer != nil {
+		if ferr := g.buffer.Flush(); err == nil {
+			err = ferr
 		}
 	}
 
-	// add the tag
-	g.Tags = append(g.Tags, tag)
+	return
+}
 
-	return g.Tags
+// Close emits any aggregated stats, writes any buffered emissions, and closes
+// the underlying Transport. Emitting after Close() returns ErrClosed. For instances made using WithTags() or
+// WithNamespace() this is the same as Flush(), as the Transport belongs to
+// the instance they were derived from.
+func (g *Godspeed) Close() (err error) {
+	if g.derived {
+		return g.Flush()
+	}
+
+	if g.closed != nil {
+		atomic.StoreInt32(g.closed, 1)
+	}
+
+	if g.telemetry != nil {
+		g.telemetry.halt()
+	}
+
+	if g.aggregator != nil {
+		err = g.aggregator.close(g)
+	}
+
+	if g.buffer != nil {
+		if berr := g.buffer.close(); err == nil {
+			err = berr
+		}
+	}
+
+	var cerr error
+
+	if g.Transport != nil {
+		cerr = g.Transport.Close()
+	} else if g.Conn != nil {
+		cerr = g.Conn.Close()
+	}
+
+	if cerr != nil {
+		return cerr
+	}
+
+	return
+}
+
+// packetLimit returns the largest datagram this instance will emit
+func (g *Godspeed) packetLimit() int {
+	if g.maxBytes > 0 {
+		return g.maxBytes
+	}
+
+	return MaxBytes
+}
+
+// AddTag allows you to add a tag for all future emitted stats.
+// It takes the tag as a string, and returns a []string containing all Godspeed tags
+func (g *Godspeed) AddTag(tag string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.addTags([]string{tag})
 }
 
 // AddTags is like AddTag(), except it tages a []string and adds each contained string
 // This also returns a []string containing the current tags
 func (g *Godspeed) AddTags(tags []string) []string {
-	// if we already have tags add each tag one at a time
-	// otherwise unique the list and assign it directly
-	if len(g.Tags) > 0 {
-		for _, tag := range tags {
-			g.AddTag(tag)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.addTags(tags)
+}
+
+// addTags adds any of the tags not already present. Instead of appending to
+// g.Tags, a new slice (with no spare capacity) is built and swapped in, so
+// that emissions using the old slice on other goroutines aren't affected.
+// g.mu must be held.
+func (g *Godspeed) addTags(tags []string) []string {
+	var added []string
+
+	for _, tag := range tags {
+		if !containsTag(g.Tags, tag) && !containsTag(added, tag) {
+			added = append(added, tag)
 		}
-	} else {
-		g.Tags = uniqueTags(tags)
 	}
 
+	// return early if the tags all already exist
+	if len(added) == 0 {
+		return g.Tags
+	}
+
+	t := make([]string, 0, len(g.Tags)+len(added))
+	t = append(t, g.Tags...)
+	g.Tags = append(t, added...)
+
 	return g.Tags
 }
 
 // SetNamespace allows you to prefix all of your metrics with a certain namespace
 func (g *Godspeed) SetNamespace(ns string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	g.Namespace = trimReserved(ns)
 }
+
+// WithNamespace returns a new Godspeed instance whose stats are namespaced
+// under this instance's namespace: <namespace>.<ns>.<statname>. See
+// WithTags() for what's shared with the new instance.
+func (g *Godspeed) WithNamespace(ns string) *Godspeed {
+	d := g.derive()
+
+	if ns = trimReserved(ns); len(d.Namespace) > 0 {
+		d.Namespace += "." + ns
+	} else {
+		d.Namespace = ns
+	}
+
+	return d
+}
+
+// WithTags returns a new Godspeed instance which adds tags to this instance's
+// tags for all of its emissions. This is useful for giving each part of a
+// program its own client, without each needing its own socket.
+//
+// The new instance shares the Transport, buffer, and aggregates with this
+// one, but has its own namespace and tags. Later changes to this instance's
+// namespace and tags don't affect it. Closing the new instance only flushes
+// it; close this instance when finished with both.
+func (g *Godspeed) WithTags(tags ...string) *Godspeed {
+	d := g.derive()
+	d.addTags(tags)
+
+	return d
+}
+
+// derive returns a copy of this instance to be used by WithTags() and
+// WithNamespace()
+func (g *Godspeed) derive() *Godspeed {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return &Godspeed{
+		Conn:         g.Conn,
+		Transport:    g.Transport,
+		Namespace:    g.Namespace,
+		Tags:         g.Tags,
+		AutoTruncate: g.AutoTruncate,
+		ContainerID:  g.ContainerID,
+		maxBytes:     g.maxBytes,
+		buffer:       g.buffer,
+		aggregator:   g.aggregator,
+		errorHandler: g.errorHandler,
+		random:       g.random,
+		telemetry:    g.telemetry,
+		closed:       g.closed,
+		derived:      true,
+	}
+}
+
+// SetErrorHandler sets a function to be called with any error hit while
+// writing in the background, such as when buffered emissions are written
+// every flush interval. See WithErrorHandler(). There's one handler per
+// connection, so this also sets it for the instance this one was derived
+// from, and for any others derived from either.
+func (g *Godspeed) SetErrorHandler(fn func(error)) {
+	g.mu.Lock()
+
+	if g.errorHandler == nil {
+		g.errorHandler = &errorHandler{}
+	}
+
+	h := g.errorHandler
+	g.mu.Unlock()
+
+	h.set(fn)
+}
+
+// handleError passes an error hit in the background to the error handler,
+// if there is one
+func (g *Godspeed) handleError(err error) {
+	g.mu.RLock()
+	h := g.errorHandler
+	g.mu.RUnlock()
+
+	h.handle(err)
+}
+
+// errorHandler holds the function errors hit in the background are passed
+// to. Its methods are safe to call on a nil *errorHandler.
+type errorHandler struct {
+	mu sync.RWMutex
+	fn func(error)
+}
+
+// set replaces the function errors are passed to
+func (h *errorHandler) set(fn func(error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fn = fn
+}
+
+// handle passes err to the function, if err isn't nil and there is one
+func (h *errorHandler) handle(err error) {
+	if err == nil || h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	fn := h.fn
+	h.mu.RUnlock()
+
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// sampled returns whether a stat with the given sample rate should be emitted
+func (g *Godspeed) sampled(sampleRate float64) bool {
+	if sampleRate >= 1 {
+		return true
+	}
+
+	if g.random != nil {
+		return g.random() < sampleRate
+	}
+
+	return rand.Float64() < sampleRate
+}
+
+// namespace returns the current namespace
+func (g *Godspeed) namespace() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.Namespace
+}
+
+// globalTags returns the current tags. AddTag() and AddTags() never modify
+// the slice once it's been set, so it can be used after the lock is released.
+func (g *Godspeed) globalTags() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.Tags
+}
+
+// containerID returns the current container ID
+func (g *Godspeed) containerID() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.ContainerID
+}
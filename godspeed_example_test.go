@@ -38,6 +38,18 @@ func ExampleNewDefault() {
 	g.Gauge("example.stat", 1, nil)
 }
 
+func ExampleNewUnix() {
+	g, err := godspeed.NewUnix("/var/run/datadog/dsd.socket", false)
+
+	if err != nil {
+		// handle error
+	}
+
+	defer g.Close()
+
+	g.Gauge("example.stat", 1, nil)
+}
+
 func ExampleGodspeed_AddTag() {
 	// be sure to handle the error
 	g, _ := godspeed.NewDefault()
@@ -5,7 +5,11 @@
 package godspeed_test
 
 import (
+	"context"
+	"fmt"
 	"net"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -70,6 +74,25 @@ func (t *TestSuite) TestNew(c *C) {
 	testBasicFunc(t, c, g)
 }
 
+func (t *TestSuite) TestNewEmptyHost(c *C) {
+	// New() and NewAsync() have always allowed an empty host, meaning the
+	// local system, unlike WithAddress()
+	g, err := godspeed.New("", 8125, false)
+	c.Assert(err, IsNil)
+
+	defer g.Close()
+
+	c.Check(g.Conn.RemoteAddr().(*net.UDPAddr).Port, Equals, 8125)
+
+	a, err := godspeed.NewAsync("", 8125, false)
+	c.Assert(err, IsNil)
+
+	defer a.Close(context.Background())
+
+	_, err = godspeed.NewClient(godspeed.WithAddress("", 8125))
+	c.Check(err, ErrorMatches, "host must not be empty")
+}
+
 func (t *TestSuite) TestNewDefault(c *C) {
 	var g *godspeed.Godspeed
 	g, err := godspeed.NewDefault()
@@ -127,3 +150,202 @@ func (t *TestSuite) TestSetNamespace(c *C) {
 	t.g.SetNamespace("heckman")
 	c.Check(t.g.Namespace, Equals, "heckman")
 }
+
+func (t *TestSuite) TestAddTagsCopies(c *C) {
+	tags := []string{"test1", "test2", "test1"}
+
+	// the caller's slice shouldn't be modified or kept
+	t.g.AddTags(tags)
+	c.Check(tags, DeepEquals, []string{"test1", "test2", "test1"})
+
+	tags[0] = "test3"
+	c.Check(t.g.Tags, DeepEquals, []string{"test1", "test2"})
+
+	// the slice returned before adding more tags shouldn't change
+	before := t.g.AddTag("test3")
+	t.g.AddTag("test4")
+
+	c.Check(before, DeepEquals, []string{"test1", "test2", "test3"})
+	c.Check(t.g.Tags, DeepEquals, []string{"test1", "test2", "test3", "test4"})
+}
+
+func (t *TestSuite) TestConcurrentConfiguration(c *C) {
+	m := &memTransport{}
+	g := godspeed.NewWithTransport(m, false)
+
+	var wg sync.WaitGroup
+
+	// run with -race to have this test be useful
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				g.AddTag(fmt.Sprintf("tag%d:%d", i, j))
+				g.AddTags([]string{"common:1", fmt.Sprintf("other%d:%d", i, j)})
+				g.SetNamespace(fmt.Sprintf("ns%d", i))
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				g.Incr("test.incr", []string{"local:1"})
+				g.Event("a", "b", nil, []string{"local:1"})
+				g.ServiceCheck("testSvc", 0, nil, []string{"local:1"})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	c.Check(len(g.Tags), Equals, 801)
+	c.Check(len(m.Writes()), Equals, 1200)
+}
+
+type UnixTestSuite struct {
+	g *godspeed.Godspeed
+	s *gspdtest.Server
+}
+
+var _ = Suite(&UnixTestSuite{})
+
+func (t *UnixTestSuite) SetUpTest(c *C) {
+	var err error
+
+	path := filepath.Join(c.MkDir(), "dsd.socket")
+
+	t.s, err = gspdtest.NewUnixServer(path)
+	c.Assert(err, IsNil)
+
+	t.g, err = godspeed.NewUnix(path, false)
+	c.Assert(err, IsNil)
+}
+
+func (t *UnixTestSuite) TearDownTest(c *C) {
+	t.g.Close()
+	t.s.Close()
+}
+
+// packet waits for the nth (starting at 1) datagram to be received, and
+// returns it
+func (t *UnixTestSuite) packet(c *C, n int) []byte {
+	ok := t.s.WaitFor(time.Second, func() bool { return len(t.s.Packets()) >= n })
+	c.Assert(ok, Equals, true, Commentf("datagram %d was not received", n))
+
+	return t.s.Packets()[n-1]
+}
+
+func (t *UnixTestSuite) TestNewUnix(c *C) {
+	c.Check(t.g.Conn, IsNil)
+
+	//
+	// test that stats, events, and service checks all make it over the socket
+	//
+	err := t.g.Send("test.metric", "c", 1, 1, nil)
+	c.Assert(err, IsNil)
+	c.Check(string(t.packet(c, 1)), Equals, "test.metric:1|c")
+
+	err = t.g.Event("a", "b", nil, []string{"test0"})
+	c.Assert(err, IsNil)
+	c.Check(string(t.packet(c, 2)), Equals, "_e{1,1}:a|b|#test0")
+
+	err = t.g.ServiceCheck("testSvc", 0, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(string(t.packet(c, 3)), Equals, "_sc|testSvc|0")
+
+	//
+	// test that a stat larger than the UDP limit is sent whole
+	//
+	for i := 0; i < 2100; i++ {
+		t.g.AddTag(fmt.Sprintf("tag%04d", i))
+	}
+
+	err = t.g.Send("test.metric", "c", 42, 1, nil)
+	c.Assert(err, IsNil)
+
+	a := t.packet(c, 4)
+	comment := Commentf("%d byte datagram: %.200q...", len(a), a)
+
+	c.Check(len(a) > godspeed.MaxBytes, Equals, true, comment)
+	c.Check(len(a) <= godspeed.MaxUnixBytes, Equals, true, comment)
+	c.Check(t.s.Count("test.metric", "tag0000", "tag2099"), Equals, 1, comment)
+	c.Check(len(t.s.Packets()), Equals, 4)
+}
+
+func (t *TestSuite) TestWithNamespaceAndTags(c *C) {
+	m := &memTransport{}
+	g := godspeed.NewWithTransport(m, false)
+	g.SetNamespace("app")
+	g.AddTag("env:test")
+
+	db := g.WithNamespace("db").WithTags("shard:3", "env:test")
+	cache := g.WithNamespace("ca|che")
+	plain := godspeed.NewWithTransport(m, false).WithTags("tag:a")
+
+	c.Check(db.Namespace, Equals, "app.db")
+	c.Check(db.Tags, DeepEquals, []string{"env:test", "shard:3"})
+	c.Check(cache.Namespace, Equals, "app.ca_che")
+	c.Check(plain.Namespace, Equals, "")
+
+	c.Assert(db.Incr("queries", []string{"table:users"}), IsNil)
+	c.Assert(cache.Incr("hits", nil), IsNil)
+	c.Assert(g.Incr("requests", nil), IsNil)
+	c.Assert(plain.Incr("requests", nil), IsNil)
+	c.Assert(db.Event("a", "b", nil, nil), IsNil)
+
+	//
+	// test that changing the parent or child doesn't affect the other
+	//
+	g.AddTag("late:1")
+	db.SetNamespace("other")
+
+	c.Assert(db.Incr("queries", nil), IsNil)
+	c.Assert(g.Incr("requests", nil), IsNil)
+
+	c.Check(m.Writes(), DeepEquals, []string{
+		"app.db.queries:1|c|#env:test,shard:3,table:users",
+		"app.ca_che.hits:1|c|#env:test",
+		"app.requests:1|c|#env:test",
+		"requests:1|c|#tag:a",
+		"_e{1,1}:a|b|#env:test,shard:3",
+		"other.queries:1|c|#env:test,shard:3",
+		"app.requests:1|c|#env:test,late:1",
+	})
+
+	//
+	// test that closing a child doesn't close the shared transport
+	//
+	c.Assert(db.Close(), IsNil)
+	c.Check(m.closed, Equals, false)
+
+	c.Assert(g.Close(), IsNil)
+	c.Check(m.closed, Equals, true)
+}
+
+func (t *TestSuite) TestWithTagsSharesBuffer(c *C) {
+	m := &memTransport{}
+	g := godspeed.NewWithTransport(m, false)
+	g.EnableBuffering(0, 0)
+	g.EnableAggregation(0)
+
+	child := g.WithTags("child:1")
+
+	c.Assert(g.Timing("test.timing", 1, nil), IsNil)
+	c.Assert(child.Timing("test.timing", 2, nil), IsNil)
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(child.Incr("test.incr", nil), IsNil)
+	c.Assert(child.Incr("test.incr", nil), IsNil)
+
+	c.Check(len(m.Writes()), Equals, 0)
+
+	// flushing the parent should write everything in one datagram
+	c.Assert(g.Flush(), IsNil)
+
+	c.Check(m.Writes(), DeepEquals, []string{
+		"test.timing:1|ms\ntest.timing:2|ms|#child:1\ntest.incr:1|c\ntest.incr:2|c|#child:1",
+	})
+}
@@ -2,8 +2,13 @@
 // Use of this source code is governed by the BSD 3-Clause
 // license that can be found in the LICENSE file.
 
-// Package gspdtest is a package used by Godspeed for testing. This package
-// isn't really meant to be consumed by anyone.
+// Package gspdtest has helpers for testing code which emits stats using
+// Godspeed. Server is a fake DogStatsD agent which parses what it receives,
+// using the dogstatsd package, so tests can check the stats, events, and
+// service checks that were sent.
+//
+// Listener, BuildListener, and BuildUnixListener are the lower level helpers
+// used by Godspeed's own tests.
 package gspdtest
 
 import (
@@ -12,10 +17,11 @@ import (
 	"net"
 )
 
-// Listener is a function which takes a *net.UDPConn and sends any data received
-// on it back over the c channel. This function is meant to be ran within a
-// goroutine. The ctrl channel is used to shut down the goroutine.
-func Listener(l *net.UDPConn, ctrl chan int, c chan []byte) {
+// Listener is a function which takes a net.Conn (*net.UDPConn or *net.UnixConn)
+// and sends any data received on it back over the c channel. This function is
+// meant to be ran within a goroutine. The ctrl channel is used to shut down the
+// goroutine.
+func Listener(l net.Conn, ctrl chan int, c chan []byte) {
 	for {
 		select {
 		case _, ok := <-ctrl:
@@ -24,7 +30,7 @@ func Listener(l *net.UDPConn, ctrl chan int, c chan []byte) {
 				return
 			}
 		default:
-			buffer := make([]byte, 8193)
+			buffer := make([]byte, 65537)
 
 			_, err := l.Read(buffer)
 
@@ -54,3 +60,15 @@ func BuildListener(port int) (*net.UDPConn, chan int, chan []byte) {
 
 	return l, make(chan int), make(chan []byte)
 }
+
+// BuildUnixListener is like BuildListener, except it builds a *net.UnixConn
+// listening for datagrams on the unix domain socket at path.
+func BuildUnixListener(path string) (*net.UnixConn, chan int, chan []byte) {
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+
+	if err != nil {
+		panic(fmt.Sprintf("unable to listen for traffic: %v", err))
+	}
+
+	return l, make(chan int), make(chan []byte)
+}
@@ -0,0 +1,301 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package gspdtest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/godspeed/dogstatsd"
+)
+
+// Metric is a single stat received by the Server
+type Metric = dogstatsd.Metric
+
+// Event is a single event received by the Server
+type Event = dogstatsd.Event
+
+// ServiceCheck is a single service check received by the Server
+type ServiceCheck = dogstatsd.ServiceCheck
+
+// T is the part of *testing.T (and gocheck's *C) used by the Server's
+// assertions
+type T interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Server is a fake DogStatsD agent. It listens on an ephemeral UDP port or a
+// unix domain datagram socket, and parses everything it receives into
+// Metrics, Events, and ServiceChecks for tests to check. Lines it can't parse
+// are kept as errors instead. It's safe for concurrent use.
+type Server struct {
+	conn net.PacketConn
+
+	mu            sync.Mutex
+	packets       [][]byte
+	metrics       []Metric
+	events        []Event
+	serviceChecks []ServiceCheck
+	errors        []error
+
+	// changed is closed, and replaced, each time a packet is received
+	changed chan struct{}
+
+	done chan struct{}
+}
+
+// NewServer starts a Server listening for UDP on an ephemeral port on
+// 127.0.0.1. Use Port() or Addr() to point a client at it.
+func NewServer() (*Server, error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newServer(conn), nil
+}
+
+// NewUnixServer starts a Server listening for datagrams on the unix domain
+// socket at path.
+func NewUnixServer(path string) (*Server, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newServer(conn), nil
+}
+
+func newServer(conn net.PacketConn) *Server {
+	s := &Server{
+		conn:    conn,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go s.serve()
+
+	return s
+}
+
+// serve reads packets until the connection is closed
+func (s *Server) serve() {
+	defer close(s.done)
+
+	buffer := make([]byte, 65537)
+
+	for {
+		n, _, err := s.conn.ReadFrom(buffer)
+
+		if err != nil {
+			return
+		}
+
+		s.receive(append([]byte(nil), buffer[:n]...))
+	}
+}
+
+// receive parses a packet, and wakes up anything waiting in WaitFor()
+func (s *Server) receive(b []byte) {
+	p := dogstatsd.Parse(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.packets = append(s.packets, b)
+	s.metrics = append(s.metrics, p.Metrics...)
+	s.events = append(s.events, p.Events...)
+	s.serviceChecks = append(s.serviceChecks, p.ServiceChecks...)
+	s.errors = append(s.errors, p.Errors...)
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Addr returns the address the Server is listening on: host:port for UDP,
+// or the socket path
+func (s *Server) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Port returns the UDP port the Server is listening on, or 0 for a unix
+// domain socket
+func (s *Server) Port() int {
+	if addr, ok := s.conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.Port
+	}
+
+	return 0
+}
+
+// Close stops the Server
+func (s *Server) Close() error {
+	err := s.conn.Close()
+	<-s.done
+
+	return err
+}
+
+// Reset forgets everything received so far
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.packets = nil
+	s.metrics = nil
+	s.events = nil
+	s.serviceChecks = nil
+	s.errors = nil
+}
+
+// Packets returns each packet received, as-is
+func (s *Server) Packets() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][]byte(nil), s.packets...)
+}
+
+// Metrics returns every stat received, in the order they were received
+func (s *Server) Metrics() []Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Metric(nil), s.metrics...)
+}
+
+// Events returns every event received, in the order they were received
+func (s *Server) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Event(nil), s.events...)
+}
+
+// ServiceChecks returns every service check received, in the order they were
+// received
+func (s *Server) ServiceChecks() []ServiceCheck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]ServiceCheck(nil), s.serviceChecks...)
+}
+
+// Errors returns a *dogstatsd.ParseError for each line received that couldn't
+// be parsed
+func (s *Server) Errors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]error(nil), s.errors...)
+}
+
+// Find returns every stat received with the given name that has all of the
+// given tags (and possibly others)
+func (s *Server) Find(name string, tags ...string) []Metric {
+	var found []Metric
+
+	for _, m := range s.Metrics() {
+		if m.Name == name && hasTags(m.Tags, tags) {
+			found = append(found, m)
+		}
+	}
+
+	return found
+}
+
+// Count returns how many stats were received with the given name and tags;
+// see Find()
+func (s *Server) Count(name string, tags ...string) int {
+	return len(s.Find(name, tags...))
+}
+
+// Sum returns the total of the values of the stats received with the given
+// name and tags; see Find(). This is most useful for counts.
+func (s *Server) Sum(name string, tags ...string) float64 {
+	var sum float64
+
+	for _, m := range s.Find(name, tags...) {
+		for _, v := range m.Values {
+			sum += v
+		}
+	}
+
+	return sum
+}
+
+// WaitFor waits for cond to return true, checking it each time a packet is
+// received. It returns false if cond is still false after timeout.
+func (s *Server) WaitFor(timeout time.Duration, cond func() bool) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		// grab the channel before checking, so nothing received in between
+		// is missed
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if cond() {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return cond()
+		}
+	}
+}
+
+// WaitForCount waits, for up to timeout, until at least n stats have been
+// received with the given name and tags. It returns whether they were.
+func (s *Server) WaitForCount(timeout time.Duration, n int, name string, tags ...string) bool {
+	return s.WaitFor(timeout, func() bool {
+		return s.Count(name, tags...) >= n
+	})
+}
+
+// AssertCount checks that exactly want stats have been received with the
+// given name and tags, reporting an error to t if not. It returns whether
+// the check passed.
+func (s *Server) AssertCount(t T, want int, name string, tags ...string) bool {
+	if got := s.Count(name, tags...); got != want {
+		if len(tags) > 0 {
+			t.Errorf("expected %d %s stats tagged %s, got %d", want, name, strings.Join(tags, ","), got)
+		} else {
+			t.Errorf("expected %d %s stats, got %d", want, name, got)
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// hasTags returns whether all of want are in tags
+func hasTags(tags, want []string) bool {
+	for _, w := range want {
+		found := false
+
+		for _, t := range tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
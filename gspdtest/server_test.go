@@ -0,0 +1,196 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package gspdtest_test
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+	"github.com/PagerDuty/godspeed/gspdtest"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ServerTestSuite struct {
+	s *gspdtest.Server
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&ServerTestSuite{})
+
+func (t *ServerTestSuite) SetUpTest(c *C) {
+	var err error
+
+	t.s, err = gspdtest.NewServer()
+	c.Assert(err, IsNil)
+
+	t.g, err = godspeed.New("127.0.0.1", t.s.Port(), false)
+	c.Assert(err, IsNil)
+}
+
+func (t *ServerTestSuite) TearDownTest(c *C) {
+	t.g.Close()
+	t.s.Close()
+}
+
+// send writes a raw packet to the server
+func (t *ServerTestSuite) send(c *C, packet string) {
+	conn, err := net.Dial("udp", t.s.Addr())
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(packet))
+	c.Assert(err, IsNil)
+}
+
+// errorRecorder is a gspdtest.T which keeps the errors reported to it
+type errorRecorder struct {
+	errors []string
+}
+
+func (e *errorRecorder) Errorf(format string, args ...interface{}) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *ServerTestSuite) TestMetrics(c *C) {
+	c.Check(t.s.Port() > 0, Equals, true)
+
+	c.Assert(t.g.Incr("test.incr", []string{"test0", "test1"}), IsNil)
+	c.Assert(t.g.Send("test.timing", "ms", 2054, 1, nil), IsNil)
+	c.Assert(t.g.Incr("test.incr", []string{"test1"}), IsNil)
+
+	c.Assert(t.s.WaitForCount(time.Second, 2, "test.incr"), Equals, true)
+
+	metrics := t.s.Metrics()
+	c.Assert(len(metrics) >= 2, Equals, true)
+	c.Check(metrics[0], DeepEquals, gspdtest.Metric{
+		Name:       "test.incr",
+		Kind:       "c",
+		Values:     []float64{1},
+		SampleRate: 1,
+		Tags:       []string{"test0", "test1"},
+	})
+
+	c.Check(t.s.Count("test.incr"), Equals, 2)
+	c.Check(t.s.Count("test.incr", "test1"), Equals, 2)
+	c.Check(t.s.Count("test.incr", "test0", "test1"), Equals, 1)
+	c.Check(t.s.Count("test.incr", "test2"), Equals, 0)
+	c.Check(t.s.Sum("test.incr"), Equals, float64(2))
+
+	c.Check(t.s.AssertCount(c, 1, "test.incr", "test0"), Equals, true)
+
+	r := &errorRecorder{}
+	c.Check(t.s.AssertCount(r, 3, "test.incr"), Equals, false)
+	c.Check(t.s.AssertCount(r, 0, "test.incr", "test0"), Equals, false)
+	c.Check(r.errors, DeepEquals, []string{
+		"expected 3 test.incr stats, got 2",
+		"expected 0 test.incr stats tagged test0, got 1",
+	})
+
+	c.Check(len(t.s.Errors()), Equals, 0)
+
+	//
+	// test that Reset() forgets everything
+	//
+	c.Assert(t.s.WaitFor(time.Second, func() bool { return len(t.s.Metrics()) == 3 }), Equals, true)
+	c.Check(len(t.s.Packets()), Equals, 3)
+
+	t.s.Reset()
+
+	c.Check(len(t.s.Metrics()), Equals, 0)
+	c.Check(len(t.s.Packets()), Equals, 0)
+	t.s.AssertCount(c, 0, "test.incr")
+}
+
+func (t *ServerTestSuite) TestParsing(c *C) {
+	t.send(c, "a.b:1:2.5:3|h|@0.25|#x:y,z|c:abc123|T1657100430\n\n_e{5,9}:title|line\\ntwo|d:1234|h:host|k:key|p:low|s:src|t:error|#a,b|c:abc123\n_sc|svc|2|d:1234|h:host|#a|m:broken")
+
+	c.Assert(t.s.WaitFor(time.Second, func() bool { return len(t.s.Packets()) == 1 }), Equals, true)
+
+	c.Check(t.s.Metrics(), DeepEquals, []gspdtest.Metric{{
+		Name:        "a.b",
+		Kind:        "h",
+		Values:      []float64{1, 2.5, 3},
+		SampleRate:  0.25,
+		Tags:        []string{"x:y", "z"},
+		ContainerID: "abc123",
+		Timestamp:   1657100430,
+	}})
+
+	c.Check(t.s.Events(), DeepEquals, []gspdtest.Event{{
+		Title:          "title",
+		Text:           "line\ntwo",
+		Timestamp:      1234,
+		Hostname:       "host",
+		AggregationKey: "key",
+		Priority:       "low",
+		SourceType:     "src",
+		AlertType:      "error",
+		Tags:           []string{"a", "b"},
+		ContainerID:    "abc123",
+	}})
+
+	c.Check(t.s.ServiceChecks(), DeepEquals, []gspdtest.ServiceCheck{{
+		Name:      "svc",
+		Status:    2,
+		Timestamp: 1234,
+		Hostname:  "host",
+		Message:   "broken",
+		Tags:      []string{"a"},
+	}})
+
+	c.Check(len(t.s.Errors()), Equals, 0)
+}
+
+func (t *ServerTestSuite) TestParseErrors(c *C) {
+	t.send(c, "nokind:1\nnovalue|c\na:x|c\na:1|c|?\n_e{5,1}:a|b\n_sc|svc|9\ngood:1|c")
+
+	c.Assert(t.s.WaitForCount(time.Second, 1, "good"), Equals, true)
+
+	errs := t.s.Errors()
+	c.Assert(len(errs), Equals, 6)
+	c.Check(errs[0], ErrorMatches, `invalid metric "nokind:1": missing kind`)
+	c.Check(errs[1], ErrorMatches, `invalid metric "novalue\|c": missing name or value`)
+	c.Check(errs[2], ErrorMatches, `invalid metric "a:x\|c": invalid value "x"`)
+	c.Check(errs[3], ErrorMatches, `invalid metric "a:1\|c\|\?": invalid field "\?"`)
+	c.Check(errs[4], ErrorMatches, `invalid event "_e\{5,1\}:a\|b": lengths don't match`)
+	c.Check(errs[5], ErrorMatches, `invalid service check "_sc\|svc\|9": invalid status "9"`)
+}
+
+func (t *ServerTestSuite) TestWaitForTimeout(c *C) {
+	start := time.Now()
+
+	c.Check(t.s.WaitForCount(20*time.Millisecond, 1, "test.never"), Equals, false)
+	c.Check(time.Since(start) >= 20*time.Millisecond, Equals, true)
+}
+
+func (t *ServerTestSuite) TestUnixServer(c *C) {
+	path := filepath.Join(c.MkDir(), "dsd.socket")
+
+	s, err := gspdtest.NewUnixServer(path)
+	c.Assert(err, IsNil)
+	defer s.Close()
+
+	c.Check(s.Addr(), Equals, path)
+	c.Check(s.Port(), Equals, 0)
+
+	g, err := godspeed.NewUnix(path, false)
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	c.Assert(g.Event("a", "b", nil, nil), IsNil)
+	c.Assert(g.ServiceCheck("svc", 0, nil, nil), IsNil)
+
+	c.Assert(s.WaitFor(time.Second, func() bool { return len(s.ServiceChecks()) == 1 }), Equals, true)
+	c.Check(s.Events(), DeepEquals, []gspdtest.Event{{Title: "a", Text: "b"}})
+	c.Check(s.ServiceChecks(), DeepEquals, []gspdtest.ServiceCheck{{Name: "svc", Status: 0}})
+}
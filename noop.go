@@ -0,0 +1,131 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import "time"
+
+// Noop has the same emission methods as Godspeed, but they do nothing and
+// always return nil. It's for when stats are turned off, such as in local
+// development or CLI tools, so that code doesn't need to check for a nil
+// client. See NewClientOrNoop() for choosing it using configuration.
+//
+// It has no state, so the zero value (or even a nil *Noop) is ready to use.
+type Noop struct{}
+
+// NewNoop returns a Noop client
+func NewNoop() *Noop {
+	return &Noop{}
+}
+
+// Send does nothing
+func (n *Noop) Send(stat, kind string, delta, sampleRate float64, tags []string) error {
+	return nil
+}
+
+// SendWithTimestamp does nothing
+func (n *Noop) SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string) error {
+	return nil
+}
+
+// Count does nothing
+func (n *Noop) Count(stat string, count float64, tags []string) error {
+	return nil
+}
+
+// CountWithTimestamp does nothing
+func (n *Noop) CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string) error {
+	return nil
+}
+
+// Incr does nothing
+func (n *Noop) Incr(stat string, tags []string) error {
+	return nil
+}
+
+// Decr does nothing
+func (n *Noop) Decr(stat string, tags []string) error {
+	return nil
+}
+
+// Gauge does nothing
+func (n *Noop) Gauge(stat string, value float64, tags []string) error {
+	return nil
+}
+
+// GaugeWithTimestamp does nothing
+func (n *Noop) GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string) error {
+	return nil
+}
+
+// Histogram does nothing
+func (n *Noop) Histogram(stat string, value float64, tags []string) error {
+	return nil
+}
+
+// Timing does nothing
+func (n *Noop) Timing(stat string, value float64, tags []string) error {
+	return nil
+}
+
+// Distribution does nothing
+func (n *Noop) Distribution(stat string, value, sampleRate float64, tags []string) error {
+	return nil
+}
+
+// Set does nothing
+func (n *Noop) Set(stat string, value float64, tags []string) error {
+	return nil
+}
+
+// Event does nothing
+func (n *Noop) Event(title, text string, fields map[string]string, tags []string) error {
+	return nil
+}
+
+// ServiceCheck does nothing
+func (n *Noop) ServiceCheck(name string, status int, fields map[string]string, tags []string) error {
+	return nil
+}
+
+// AddTag does nothing, and returns nil as there are never any tags
+func (n *Noop) AddTag(tag string) []string {
+	return nil
+}
+
+// AddTags does nothing, and returns nil as there are never any tags
+func (n *Noop) AddTags(tags []string) []string {
+	return nil
+}
+
+// SetNamespace does nothing
+func (n *Noop) SetNamespace(ns string) {}
+
+// WithNamespace returns this Noop, as there's no namespace to change
+func (n *Noop) WithNamespace(ns string) *Noop {
+	return n
+}
+
+// WithTags returns this Noop, as there are no tags to change
+func (n *Noop) WithTags(tags ...string) *Noop {
+	return n
+}
+
+// SetErrorHandler does nothing, as there are never any errors
+func (n *Noop) SetErrorHandler(fn func(error)) {}
+
+// Stats returns an empty Stats, as nothing is ever sent
+func (n *Noop) Stats() Stats {
+	return Stats{}
+}
+
+// Flush does nothing
+func (n *Noop) Flush() error {
+	return nil
+}
+
+// Close does nothing
+func (n *Noop) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type NoopTestSuite struct{}
+
+var _ = Suite(&NoopTestSuite{})
+
+func (t *NoopTestSuite) TestNoop(c *C) {
+	n := godspeed.NewNoop()
+
+	c.Check(n.WithNamespace("ns").WithTags("a"), Equals, n)
+	c.Check(n.Stats(), DeepEquals, godspeed.Stats{})
+	n.SetErrorHandler(func(error) { c.Error("error handler was called") })
+
+	// invalid emissions are ignored too
+	c.Check(n.Event("", "", nil, nil), IsNil)
+	c.Check(n.ServiceCheck("svc", 9, nil, nil), IsNil)
+	c.Check(n.SendWithTimestamp("test.hist", "h", 1, time.Time{}, nil), IsNil)
+
+	// and so is emitting after it's closed
+	c.Check(n.Close(), IsNil)
+	c.Check(n.Incr("test.incr", nil), IsNil)
+
+	// a nil *Noop works just as well
+	var cl godspeed.Client = (*godspeed.Noop)(nil)
+	c.Check(cl.Gauge("test.gauge", 1, nil), IsNil)
+	c.Check(cl.AddTag("a"), IsNil)
+}
+
+func (t *NoopTestSuite) TestNewClientOrNoop(c *C) {
+	m := &memTransport{}
+
+	cl, err := godspeed.NewClientOrNoop(godspeed.WithTransport(m), godspeed.WithDisabled(true))
+	c.Assert(err, IsNil)
+
+	_, ok := cl.(*godspeed.Noop)
+	c.Assert(ok, Equals, true)
+
+	c.Check(cl.Incr("test.incr", nil), IsNil)
+	c.Check(cl.Event("title", "text", nil, nil), IsNil)
+	c.Check(cl.Close(), IsNil)
+
+	c.Check(len(m.Writes()), Equals, 0)
+	c.Check(m.closed, Equals, false)
+
+	cl, err = godspeed.NewClientOrNoop(godspeed.WithTransport(m), godspeed.WithDisabled(false))
+	c.Assert(err, IsNil)
+
+	emitAll(c, cl)
+
+	c.Check(m.Writes(), DeepEquals, expectedLines)
+
+	// options are still validated
+	cl, err = godspeed.NewClientOrNoop(godspeed.WithMaxBytes(0), godspeed.WithDisabled(true))
+	c.Check(cl, IsNil)
+	c.Check(err, ErrorMatches, "max bytes must be at least 1, got 0")
+
+	// the other constructors can't return a Noop
+	_, err = godspeed.NewClient(godspeed.WithDisabled(true))
+	c.Check(err, Equals, godspeed.ErrDisabled)
+
+	_, err = godspeed.NewAsyncClient(godspeed.WithDisabled(true))
+	c.Check(err, Equals, godspeed.ErrDisabled)
+}
@@ -0,0 +1,486 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultFlushInterval is how often buffered emissions are written when
+// buffering is enabled using WithBuffering() and no WithFlushInterval() is
+// given
+const DefaultFlushInterval = 100 * time.Millisecond
+
+// options is the configuration built up by the Option functions given to
+// NewClient() and NewAsyncClient()
+type options struct {
+	network string
+	host    string
+	port    int
+
+	// reResolve is how often the host is looked up again; 0 means it's only
+	// looked up when connecting
+	reResolve time.Duration
+
+	transport    Transport
+	namespace    string
+	tags         []string
+	autoTruncate bool
+	maxBytes     int
+
+	buffering     bool
+	bufferSize    int
+	flushInterval time.Duration
+
+	queueSize int
+	workers   int
+	block     bool
+
+	errorHandler func(error)
+	random       func() float64
+
+	telemetryInterval time.Duration
+
+	envTags         bool
+	originDetection bool
+
+	// disabled is set by WithDisabled(); when it's nil DD_DOGSTATSD_DISABLE
+	// decides whether NewClientOrNoop() returns a Noop
+	disabled *bool
+}
+
+// Option configures a client built using NewClient() or NewAsyncClient()
+type Option func(*options) error
+
+// WithAddress has the client emit over UDP to host and port. This is the
+// default, using DefaultHost and DefaultPort.
+func WithAddress(host string, port int) Option {
+	return func(o *options) error {
+		if len(host) == 0 {
+			return fmt.Errorf("host must not be empty")
+		}
+
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("port must be a number between 1 and 65535")
+		}
+
+		return withLegacyAddress(host, port)(o)
+	}
+}
+
+// withLegacyAddress is WithAddress() without the validation, for New() and
+// NewAsync(), which have always passed the host and port straight through
+// (so an empty host means the local system)
+func withLegacyAddress(host string, port int) Option {
+	return func(o *options) error {
+		o.network, o.host, o.port = "udp", host, port
+		return nil
+	}
+}
+
+// WithReResolve has the client look up the host given to WithAddress() (or
+// the default host) again every interval, and after writes are refused,
+// switching to the new address when it changes. This uses a
+// ResolvingUDPTransport, and any errors looking up the host are passed to the
+// handler given to WithErrorHandler().
+func WithReResolve(interval time.Duration) Option {
+	return func(o *options) error {
+		if interval <= 0 {
+			return fmt.Errorf("re-resolve interval must be positive, got %v", interval)
+		}
+
+		o.reResolve = interval
+		return nil
+	}
+}
+
+// WithUnixSocket has the client emit over the unix domain datagram socket at
+// path, like NewUnix()
+func WithUnixSocket(path string) Option {
+	return func(o *options) error {
+		if len(path) == 0 {
+			return fmt.Errorf("socket path must not be empty")
+		}
+
+		o.network, o.host, o.port = "unixgram", path, 0
+		return nil
+	}
+}
+
+// WithStream has the client emit over a stream socket using a
+// StreamTransport, like NewStream(). The network must be "tcp", "tcp4",
+// "tcp6", or "unix".
+func WithStream(network, address string) Option {
+	return func(o *options) error {
+		switch network {
+		case "tcp", "tcp4", "tcp6", "unix":
+		default:
+			return fmt.Errorf("unsupported stream network %q; must be tcp, tcp4, tcp6, or unix", network)
+		}
+
+		if len(address) == 0 {
+			return fmt.Errorf("stream address must not be empty")
+		}
+
+		o.network, o.host, o.port = network, address, 0
+		return nil
+	}
+}
+
+// WithTransport has the client write all emissions through t, like
+// NewWithTransport(). It takes precedence over WithAddress() and
+// WithUnixSocket().
+func WithTransport(t Transport) Option {
+	return func(o *options) error {
+		if t == nil {
+			return fmt.Errorf("transport must not be nil")
+		}
+
+		o.transport = t
+		return nil
+	}
+}
+
+// WithNamespace sets the namespace all stats are prefixed with, like
+// SetNamespace()
+func WithNamespace(ns string) Option {
+	return func(o *options) error {
+		o.namespace = ns
+		return nil
+	}
+}
+
+// WithGlobalTags adds tags to every emission, like AddTags(). It may be given
+// more than once.
+func WithGlobalTags(tags ...string) Option {
+	return func(o *options) error {
+		o.tags = append(o.tags, tags...)
+		return nil
+	}
+}
+
+// WithAutoTruncate sets whether stats too large for a single datagram are
+// truncated rather than returning an error. See the AutoTruncate field.
+func WithAutoTruncate(autoTruncate bool) Option {
+	return func(o *options) error {
+		o.autoTruncate = autoTruncate
+		return nil
+	}
+}
+
+// WithMaxBytes sets the largest datagram the client will emit. By default
+// that's MaxBytes, or MaxUnixBytes for unix domain sockets.
+func WithMaxBytes(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("max bytes must be at least 1, got %d", n)
+		}
+
+		o.maxBytes = n
+		return nil
+	}
+}
+
+// WithBuffering has the client pack emissions into datagrams of up to size
+// bytes, like EnableBuffering(). A size less than 1 uses the packet limit.
+// Buffered emissions are written every DefaultFlushInterval unless
+// WithFlushInterval() is given.
+func WithBuffering(size int) Option {
+	return func(o *options) error {
+		o.buffering, o.bufferSize = true, size
+		return nil
+	}
+}
+
+// WithFlushInterval sets how often buffered emissions are written when
+// WithBuffering() is given. An interval less than 1 only writes them when the
+// datagram is full, or when Flush() or Close() are called.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *options) error {
+		o.flushInterval = d
+		return nil
+	}
+}
+
+// WithQueueSize sets the number of emissions NewAsyncClient() queues up. It
+// defaults to DefaultQueueSize, and is ignored by NewClient().
+func WithQueueSize(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("queue size must be at least 1, got %d", n)
+		}
+
+		o.queueSize = n
+		return nil
+	}
+}
+
+// WithWorkers sets the number of goroutines NewAsyncClient() uses to write
+// queued emissions. It defaults to DefaultWorkers, and is ignored by
+// NewClient().
+func WithWorkers(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return fmt.Errorf("workers must be at least 1, got %d", n)
+		}
+
+		o.workers = n
+		return nil
+	}
+}
+
+// WithBlockOnFullQueue has emissions to an instance built using
+// NewAsyncClient() wait for room in the queue when it's full, instead of being
+// dropped. It's ignored by NewClient().
+func WithBlockOnFullQueue() Option {
+	return func(o *options) error {
+		o.block = true
+		return nil
+	}
+}
+
+// WithErrorHandler sets a function to be called with any error hit while
+// writing in the background, such as when buffered emissions are written
+// every flush interval. For NewAsyncClient() it's also given an *AsyncError
+// for each emission that fails or is dropped. These errors are dropped by
+// default, as there's no caller to return them to. The function may be
+// called from multiple goroutines at once.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) error {
+		o.errorHandler = fn
+		return nil
+	}
+}
+
+// WithSampleRateSource sets the function used to decide whether a sampled
+// stat is emitted. It must return a number in [0.0, 1.0), and the stat is
+// emitted when that number is less than the sample rate. It defaults to
+// rand.Float64 from math/rand; providing one is mostly useful for tests.
+func WithSampleRateSource(fn func() float64) Option {
+	return func(o *options) error {
+		if fn == nil {
+			return fmt.Errorf("sample rate source must not be nil")
+		}
+
+		o.random = fn
+		return nil
+	}
+}
+
+// WithTelemetry has the client emit the counts from Stats() every interval,
+// like EnableTelemetry()
+func WithTelemetry(interval time.Duration) Option {
+	return func(o *options) error {
+		if interval <= 0 {
+			return fmt.Errorf("telemetry interval must be positive, got %v", interval)
+		}
+
+		o.telemetryInterval = interval
+		return nil
+	}
+}
+
+// WithEnvTags sets whether the tags from EnvTags() are added to every
+// emission, for Datadog's unified service tagging. They come before any
+// given to WithGlobalTags(). NewFromEnv() adds them unless it's given
+// WithEnvTags(false).
+func WithEnvTags(enabled bool) Option {
+	return func(o *options) error {
+		o.envTags = enabled
+		return nil
+	}
+}
+
+// WithOriginDetection sets whether origin detection is enabled, like
+// EnableOriginDetection(). NewFromEnv() enables it unless it's given
+// WithOriginDetection(false), or DD_ORIGIN_DETECTION_ENABLED is false.
+func WithOriginDetection(enabled bool) Option {
+	return func(o *options) error {
+		o.originDetection = enabled
+		return nil
+	}
+}
+
+// WithDisabled decides whether NewClientOrNoop() returns a Noop client,
+// overriding the DD_DOGSTATSD_DISABLE environment variable. NewClient() and
+// NewAsyncClient() return ErrDisabled when given WithDisabled(true).
+func WithDisabled(disabled bool) Option {
+	return func(o *options) error {
+		o.disabled = &disabled
+		return nil
+	}
+}
+
+// buildOptions applies opts on top of the defaults
+func buildOptions(opts []Option) (*options, error) {
+	o := &options{
+		network:       "udp",
+		host:          DefaultHost,
+		port:          DefaultPort,
+		flushInterval: DefaultFlushInterval,
+		queueSize:     DefaultQueueSize,
+		workers:       DefaultWorkers,
+	}
+
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// dial connects to the agent, unless a Transport was given. onError is
+// given errors hit by the Transport in the background.
+func (o *options) dial(onError func(error)) (Transport, error) {
+	if o.transport != nil {
+		return o.transport, nil
+	}
+
+	switch o.network {
+	case "unixgram":
+		return net.DialUnix("unixgram", nil, &net.UnixAddr{Name: o.host, Net: "unixgram"})
+	case "tcp", "tcp4", "tcp6", "unix":
+		return NewStreamTransport(o.network, o.host)
+	}
+
+	if o.reResolve > 0 {
+		return newResolvingUDPTransport(o.host, o.port, o.reResolve, onError)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(o.host, strconv.Itoa(o.port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialUDP("udp", nil, addr)
+}
+
+// NewClient returns a new instance of a Godspeed statsd client configured by
+// opts. With no options it's the same as NewDefault(). An error is returned
+// if any of the options are invalid, or if the connection can't be set up.
+func NewClient(opts ...Option) (*Godspeed, error) {
+	o, err := buildOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return o.client()
+}
+
+// NewAsyncClient is like NewClient() except it returns an instance of
+// AsyncGodspeed. The queue is configured using WithQueueSize(),
+// WithWorkers(), and WithBlockOnFullQueue().
+func NewAsyncClient(opts ...Option) (*AsyncGodspeed, error) {
+	o, err := buildOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := o.client()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAsyncWithQueue(g, o.queueSize, o.workers, o.block), nil
+}
+
+// NewClientOrNoop is like NewClient(), except it returns a Noop client when
+// stats are turned off: either by the WithDisabled() option, or, if that
+// isn't given, by setting the DD_DOGSTATSD_DISABLE environment variable to
+// true (or 1). An error is returned if DD_DOGSTATSD_DISABLE is set to
+// something other than a boolean.
+func NewClientOrNoop(opts ...Option) (Client, error) {
+	o, err := buildOptions(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	disabled, err := o.isDisabled()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if disabled {
+		return NewNoop(), nil
+	}
+
+	g, err := o.client()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// isDisabled returns whether the client has been turned off, using the
+// WithDisabled() option if it was given or DD_DOGSTATSD_DISABLE if not
+func (o *options) isDisabled() (bool, error) {
+	if o.disabled != nil {
+		return *o.disabled, nil
+	}
+
+	return envDisabled()
+}
+
+// client builds the Godspeed instance described by the options
+func (o *options) client() (*Godspeed, error) {
+	if o.disabled != nil && *o.disabled {
+		return nil, ErrDisabled
+	}
+
+	handler := &errorHandler{fn: o.errorHandler}
+
+	t, err := o.dial(handler.handle)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewWithTransport(t, o.autoTruncate)
+
+	if o.maxBytes > 0 {
+		g.maxBytes = o.maxBytes
+	}
+
+	if len(o.namespace) > 0 {
+		g.SetNamespace(o.namespace)
+	}
+
+	if o.envTags {
+		g.AddTags(EnvTags())
+	}
+
+	if len(o.tags) > 0 {
+		g.AddTags(o.tags)
+	}
+
+	if o.originDetection {
+		g.EnableOriginDetection()
+	}
+
+	g.errorHandler = handler
+	g.random = o.random
+
+	if o.buffering {
+		g.EnableBuffering(o.bufferSize, o.flushInterval)
+	}
+
+	if o.telemetryInterval > 0 {
+		g.EnableTelemetry(o.telemetryInterval)
+	}
+
+	return g, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+// errTransport is a godspeed.Transport whose writes always fail
+type errTransport struct{}
+
+func (errTransport) Write(b []byte) (int, error) { return 0, errors.New("write failed") }
+func (errTransport) Close() error                { return nil }
+
+type OptionsTestSuite struct {
+	m *memTransport
+}
+
+var _ = Suite(&OptionsTestSuite{})
+
+func (t *OptionsTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+}
+
+func (t *OptionsTestSuite) TestNewClientDefaults(c *C) {
+	g, err := godspeed.NewClient()
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	c.Check(g.Conn, NotNil)
+	c.Check(g.Conn.RemoteAddr().String(), Equals, "127.0.0.1:8125")
+	c.Check(g.Namespace, Equals, "")
+	c.Check(len(g.Tags), Equals, 0)
+	c.Check(g.AutoTruncate, Equals, false)
+}
+
+func (t *OptionsTestSuite) TestNewClient(c *C) {
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(t.m),
+		godspeed.WithNamespace("example"),
+		godspeed.WithGlobalTags("test0", "test1"),
+		godspeed.WithGlobalTags("test1", "test2"),
+		godspeed.WithAutoTruncate(true),
+		godspeed.WithMaxBytes(48),
+	)
+	c.Assert(err, IsNil)
+
+	c.Check(g.Transport, Equals, godspeed.Transport(t.m))
+	c.Check(g.Namespace, Equals, "example")
+	c.Check(g.Tags, DeepEquals, []string{"test0", "test1", "test2"})
+	c.Check(g.AutoTruncate, Equals, true)
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(g.Incr("test.incr.that.is.quite.long", nil), IsNil)
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 2)
+	c.Check(writes[0], Equals, "example.test.incr:1|c|#test0,test1,test2")
+	c.Check(writes[1], Equals, "example.test.incr.that.is.quite.long:1|c|#test0,")
+
+	c.Assert(g.Close(), IsNil)
+	c.Check(t.m.closed, Equals, true)
+}
+
+func (t *OptionsTestSuite) TestNewClientBuffering(c *C) {
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(t.m),
+		godspeed.WithBuffering(0),
+		godspeed.WithFlushInterval(0),
+	)
+	c.Assert(err, IsNil)
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(g.Gauge("test.gauge", 42, nil), IsNil)
+	c.Check(len(t.m.Writes()), Equals, 0)
+
+	c.Assert(g.Close(), IsNil)
+	c.Check(t.m.Writes(), DeepEquals, []string{"test.incr:1|c\ntest.gauge:42|g"})
+}
+
+func (t *OptionsTestSuite) TestNewClientErrorHandler(c *C) {
+	errs := make(chan error, 1)
+
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(errTransport{}),
+		godspeed.WithBuffering(0),
+		godspeed.WithFlushInterval(10*time.Millisecond),
+		godspeed.WithErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}),
+	)
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+
+	select {
+	case err := <-errs:
+		c.Check(err, ErrorMatches, "write failed")
+	case <-time.After(time.Second):
+		c.Fatal("error handler was not called")
+	}
+}
+
+func (t *OptionsTestSuite) TestNewClientSampleRateSource(c *C) {
+	r := 0.5
+
+	g, err := godspeed.NewClient(
+		godspeed.WithTransport(t.m),
+		godspeed.WithSampleRateSource(func() float64 { return r }),
+	)
+	c.Assert(err, IsNil)
+
+	c.Assert(g.Count("test.count", 1, nil), IsNil)
+	c.Assert(g.Send("test.sampled", "c", 1, 0.6, nil), IsNil)
+	c.Assert(g.Send("test.dropped", "c", 1, 0.5, nil), IsNil)
+
+	// derived instances use the same source
+	c.Assert(g.WithTags("derived").Send("test.derived", "c", 1, 0.4, nil), IsNil)
+
+	c.Check(t.m.Writes(), DeepEquals, []string{
+		"test.count:1|c",
+		"test.sampled:1|c|@0.6",
+	})
+}
+
+func (t *OptionsTestSuite) TestNewClientInvalid(c *C) {
+	for _, opt := range []struct {
+		opt godspeed.Option
+		err string
+	}{
+		{godspeed.WithAddress("", 8125), "host must not be empty"},
+		{godspeed.WithAddress("127.0.0.1", 0), "port must be a number between 1 and 65535"},
+		{godspeed.WithUnixSocket(""), "socket path must not be empty"},
+		{godspeed.WithTransport(nil), "transport must not be nil"},
+		{godspeed.WithMaxBytes(0), "max bytes must be at least 1, got 0"},
+		{godspeed.WithQueueSize(0), "queue size must be at least 1, got 0"},
+		{godspeed.WithWorkers(-1), "workers must be at least 1, got -1"},
+		{godspeed.WithSampleRateSource(nil), "sample rate source must not be nil"},
+	} {
+		g, err := godspeed.NewClient(opt.opt)
+		c.Check(g, IsNil)
+		c.Check(err, ErrorMatches, opt.err)
+
+		a, err := godspeed.NewAsyncClient(opt.opt)
+		c.Check(a, IsNil)
+		c.Check(err, ErrorMatches, opt.err)
+	}
+}
+
+func (t *OptionsTestSuite) TestNewAsyncClient(c *C) {
+	a, err := godspeed.NewAsyncClient(
+		godspeed.WithTransport(t.m),
+		godspeed.WithNamespace("example"),
+		godspeed.WithQueueSize(1),
+		godspeed.WithWorkers(2),
+		godspeed.WithBlockOnFullQueue(),
+	)
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 10; i++ {
+		a.Incr("test.incr", nil, nil)
+	}
+
+	c.Assert(a.Close(context.Background()), IsNil)
+
+	// blocking on a full queue means nothing was dropped
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 10)
+	c.Check(writes[0], Equals, "example.test.incr:1|c")
+}
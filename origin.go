@@ -0,0 +1,104 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// cgroupPath is where the cgroups for this process are listed
+	cgroupPath = "/proc/self/cgroup"
+
+	envEntityID        = "DD_ENTITY_ID"
+	envOriginDetection = "DD_ORIGIN_DETECTION_ENABLED"
+
+	// entityIDTag is the tag the agent uses for origin detection when it's
+	// given an entity ID (the pod UID) instead of a container ID
+	entityIDTag = "dd.internal.entity_id:"
+)
+
+var (
+	// cgroupLine matches a line in /proc/self/cgroup, with the path in the
+	// first group. cgroup v1 lines are hierarchy-ID:controllers:path while
+	// cgroup v2 lines are 0::path.
+	cgroupLine = regexp.MustCompile(`^\d+:[^:]*:(.+)$`)
+
+	// containerIDExp matches the container ID at the end of a cgroup path.
+	// It's a 64 character hex ID (Docker, containerd, CRI-O), a UUID (used
+	// by some runtimes), or an ECS task ID. The systemd .scope suffix is
+	// allowed, as is the runtime prefix (e.g., docker-<id>.scope).
+	containerIDExp = regexp.MustCompile(`([0-9a-f]{64}|[0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12}|[0-9a-f]{32}-\d+)(?:\.scope)?$`)
+)
+
+// DetectContainerID returns the ID of the container this process is running
+// in, found using /proc/self/cgroup. Both cgroup v1 and v2 are supported,
+// although with cgroup v2 the ID is only visible when the container doesn't
+// have its own cgroup namespace. An empty string is returned if the ID can't
+// be found, such as when not running in a container.
+func DetectContainerID() string {
+	id, _ := readContainerID(cgroupPath)
+	return id
+}
+
+// readContainerID returns the first container ID found in the cgroup file
+func readContainerID(path string) (string, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		m := cgroupLine.FindStringSubmatch(scanner.Text())
+
+		if m == nil {
+			continue
+		}
+
+		parts := strings.Split(m[1], "/")
+
+		if id := containerIDExp.FindStringSubmatch(parts[len(parts)-1]); id != nil {
+			return id[1], nil
+		}
+	}
+
+	return "", scanner.Err()
+}
+
+// EnableOriginDetection sets things up so that the agent can tell which
+// container (or pod) emissions came from, so they get the right tags. If
+// DD_ENTITY_ID is set, it's added as a tag for the agent to use. Otherwise
+// the ContainerID is set using DetectContainerID() unless it's already set.
+func (g *Godspeed) EnableOriginDetection() {
+	if id := os.Getenv(envEntityID); len(id) > 0 {
+		g.AddTag(entityIDTag + id)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.ContainerID) == 0 {
+		g.ContainerID = DetectContainerID()
+	}
+}
+
+// writeContainerID adds the container ID field (DogStatsD protocol v1.2) to
+// the end of an emission, if the ContainerID is set
+func (g *Godspeed) writeContainerID(buf *bytes.Buffer) {
+	if id := g.containerID(); len(id) > 0 {
+		buf.WriteString("|c:")
+		buf.WriteString(id)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type OriginTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&OriginTestSuite{})
+
+func (t *OriginTestSuite) SetUpTest(c *C) {
+	var err error
+
+	t.m = &memTransport{}
+	t.g, err = godspeed.NewClient(godspeed.WithTransport(t.m), alwaysSample)
+	c.Assert(err, IsNil)
+}
+
+func (t *OriginTestSuite) TearDownTest(c *C) {
+	os.Unsetenv("DD_ENTITY_ID")
+}
+
+func (t *OriginTestSuite) TestReadContainerID(c *C) {
+	tests := map[string]string{
+		"cgroup_v1":            "3726184226f5d3147c25fdeab5b60097e378e8a720503a5e19ecfdf29f869860",
+		"cgroup_v1_kubernetes": "3e74d3fd9db4c9dd921ae05c2502fb984d0cde1b36e581b13f79c639da4518a1",
+		"cgroup_v1_ecs":        "38fac3e99302b3622be089dd41e7ccf38aff368a86cc339972075136ee2710ce",
+		"cgroup_v1_fargate":    "432624d2150b349fe35ba397284dea788c2bf66b885d14dfc1569b01890ca7da",
+		"cgroup_v2":            "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789",
+		"cgroup_v2_kubernetes": "7fe1bfa19f84a81f2fa4e5c6e2ff5bd71ebcbbc2d8cb8ecd4a63233a42ffc1de",
+		"cgroup_v2_private":    "",
+		"cgroup_host":          "",
+	}
+
+	for file, id := range tests {
+		found, err := godspeed.ReadContainerID(filepath.Join("testdata", file))
+		c.Assert(err, IsNil)
+		c.Check(found, Equals, id, Commentf("fixture: %s", file))
+	}
+
+	_, err := godspeed.ReadContainerID(filepath.Join("testdata", "nonexistent"))
+	c.Check(err, NotNil)
+}
+
+func (t *OriginTestSuite) TestContainerID(c *C) {
+	t.g.ContainerID = "abc123"
+	t.g.AddTag("tag:a")
+
+	c.Assert(t.g.Send("test.metric", "c", 1, 0.99, nil), IsNil)
+	c.Assert(t.g.Event("a", "b", nil, nil), IsNil)
+	c.Assert(t.g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+
+	c.Check(t.m.Writes(), DeepEquals, []string{
+		"test.metric:1|c|@0.99|#tag:a|c:abc123",
+		"_e{1,1}:a|b|#tag:a|c:abc123",
+		"_sc|testSvc|0|#tag:a|c:abc123",
+	})
+}
+
+func (t *OriginTestSuite) TestEnableOriginDetection(c *C) {
+	//
+	// test that an explicit container ID is kept
+	//
+	t.g.ContainerID = "abc123"
+	t.g.EnableOriginDetection()
+	c.Check(t.g.ContainerID, Equals, "abc123")
+	c.Check(len(t.g.Tags), Equals, 0)
+
+	//
+	// test that DD_ENTITY_ID is used as a tag when set
+	//
+	os.Setenv("DD_ENTITY_ID", "3d274242-8ee0-11e9-a8a6-1e68d864ef1a")
+
+	t.g.ContainerID = ""
+	t.g.EnableOriginDetection()
+	c.Check(t.g.ContainerID, Equals, "")
+	c.Check(t.g.Tags, DeepEquals, []string{"dd.internal.entity_id:3d274242-8ee0-11e9-a8a6-1e68d864ef1a"})
+
+	//
+	// test that the container ID is detected otherwise
+	//
+	os.Unsetenv("DD_ENTITY_ID")
+
+	t.g.EnableOriginDetection()
+	c.Check(t.g.ContainerID, Equals, godspeed.DetectContainerID())
+}
@@ -0,0 +1,442 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"sync"
+	"time"
+)
+
+// RecordedStat is a single stat captured by a Recorder
+type RecordedStat struct {
+	// Name is the full name of the stat, including the namespace
+	Name string
+
+	// Kind is the stat kind: "c", "g", "ms", "h", "s", or "d"
+	Kind string
+
+	Value      float64
+	SampleRate float64
+
+	// Tags are the Recorder's tags merged with those passed in
+	Tags []string
+
+	// Timestamp is the time passed to SendWithTimestamp(), or the zero Time
+	Timestamp time.Time
+}
+
+// RecordedEvent is a single event captured by a Recorder
+type RecordedEvent struct {
+	Title  string
+	Text   string
+	Fields map[string]string
+
+	// Tags are the Recorder's tags merged with those passed in
+	Tags []string
+}
+
+// RecordedServiceCheck is a single service check captured by a Recorder
+type RecordedServiceCheck struct {
+	Name   string
+	Status int
+	Fields map[string]string
+
+	// Tags are the Recorder's tags merged with those passed in
+	Tags []string
+}
+
+// Recorder has the same emission methods as Godspeed, but instead of sending
+// anything it keeps every stat, event, and service check in memory, with the
+// namespace and tags applied as Godspeed would. It's meant for unit testing
+// code which emits stats, without needing a socket. Every stat is kept, no
+// matter the sample rate, and emissions are validated like they are by
+// Godspeed, so the same errors are returned.
+//
+// It's safe for concurrent use.
+type Recorder struct {
+	// Namespace and Tags work the same as Godspeed's
+	Namespace string
+	Tags      []string
+
+	mu      sync.RWMutex
+	rec     *recording
+	derived bool
+}
+
+// recording is everything captured by a Recorder, and those derived from it
+type recording struct {
+	mu            sync.Mutex
+	stats         []RecordedStat
+	events        []RecordedEvent
+	serviceChecks []RecordedServiceCheck
+	closed        bool
+}
+
+// NewRecorder returns a Recorder with no namespace or tags
+func NewRecorder() *Recorder {
+	return &Recorder{rec: &recording{}}
+}
+
+// Send records a stat; see Godspeed.Send()
+func (r *Recorder) Send(stat, kind string, delta, sampleRate float64, tags []string) error {
+	return r.record(RecordedStat{
+		Name:       r.statName(stat),
+		Kind:       kind,
+		Value:      delta,
+		SampleRate: sampleRate,
+		Tags:       mergeTags(r.globalTags(), tags),
+	})
+}
+
+// SendWithTimestamp records a stat with a timestamp; see
+// Godspeed.SendWithTimestamp()
+func (r *Recorder) SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string) error {
+	if err := validateTimestamp(kind, timestamp); err != nil {
+		return err
+	}
+
+	return r.record(RecordedStat{
+		Name:       r.statName(stat),
+		Kind:       kind,
+		Value:      delta,
+		SampleRate: 1,
+		Tags:       mergeTags(r.globalTags(), tags),
+		Timestamp:  timestamp,
+	})
+}
+
+// Count wraps Send() and simplifies the interface for Count stats
+func (r *Recorder) Count(stat string, count float64, tags []string) error {
+	return r.Send(stat, "c", count, 1, tags)
+}
+
+// Incr wraps Send() and simplifies the interface for incrementing a counter
+func (r *Recorder) Incr(stat string, tags []string) error {
+	return r.Count(stat, 1, tags)
+}
+
+// Decr wraps Send() and simplifies the interface for decrementing a counter
+func (r *Recorder) Decr(stat string, tags []string) error {
+	return r.Count(stat, -1, tags)
+}
+
+// CountWithTimestamp wraps SendWithTimestamp() for Count stats
+func (r *Recorder) CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string) error {
+	return r.SendWithTimestamp(stat, "c", count, timestamp, tags)
+}
+
+// Gauge wraps Send() and simplifies the interface for Gauge stats
+func (r *Recorder) Gauge(stat string, value float64, tags []string) error {
+	return r.Send(stat, "g", value, 1, tags)
+}
+
+// GaugeWithTimestamp wraps SendWithTimestamp() for Gauge stats
+func (r *Recorder) GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string) error {
+	return r.SendWithTimestamp(stat, "g", value, timestamp, tags)
+}
+
+// Histogram wraps Send() and simplifies the interface for Histogram stats
+func (r *Recorder) Histogram(stat string, value float64, tags []string) error {
+	return r.Send(stat, "h", value, 1, tags)
+}
+
+// Timing wraps Send() and simplifies the interface for Timing stats
+func (r *Recorder) Timing(stat string, value float64, tags []string) error {
+	return r.Send(stat, "ms", value, 1, tags)
+}
+
+// Distribution wraps Send() and simplifies the interface for Distribution stats
+func (r *Recorder) Distribution(stat string, value, sampleRate float64, tags []string) error {
+	return r.Send(stat, "d", value, sampleRate, tags)
+}
+
+// Set wraps Send() and simplifies the interface for Set stats
+func (r *Recorder) Set(stat string, value float64, tags []string) error {
+	return r.Send(stat, "s", value, 1, tags)
+}
+
+// Event records an event; see Godspeed.Event()
+func (r *Recorder) Event(title, text string, fields map[string]string, tags []string) error {
+	if len(title) < 1 {
+		return &InvalidNameError{Kind: "event", Name: title}
+	}
+
+	if len(text) < 1 {
+		return ErrEmptyBody
+	}
+
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	if r.rec.closed {
+		return ErrClosed
+	}
+
+	r.rec.events = append(r.rec.events, RecordedEvent{
+		Title:  title,
+		Text:   text,
+		Fields: copyFields(fields),
+		Tags:   mergeTags(r.globalTags(), tags),
+	})
+
+	return nil
+}
+
+// ServiceCheck records a service check; see Godspeed.ServiceCheck()
+func (r *Recorder) ServiceCheck(name string, status int, fields map[string]string, tags []string) error {
+	if err := validateServiceCheck(name, status); err != nil {
+		return err
+	}
+
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	if r.rec.closed {
+		return ErrClosed
+	}
+
+	r.rec.serviceChecks = append(r.rec.serviceChecks, RecordedServiceCheck{
+		Name:   name,
+		Status: status,
+		Fields: copyFields(fields),
+		Tags:   mergeTags(r.globalTags(), tags),
+	})
+
+	return nil
+}
+
+// record keeps a stat, unless the Recorder has been closed
+func (r *Recorder) record(s RecordedStat) error {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	if r.rec.closed {
+		return ErrClosed
+	}
+
+	r.rec.stats = append(r.rec.stats, s)
+
+	return nil
+}
+
+// AddTag adds a tag for all future emissions, and returns all of the tags
+func (r *Recorder) AddTag(tag string) []string {
+	return r.AddTags([]string{tag})
+}
+
+// AddTags is like AddTag(), except it adds each of the tags
+func (r *Recorder) AddTags(tags []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Tags = mergeTags(r.Tags, tags)
+
+	return r.Tags
+}
+
+// SetNamespace sets the namespace prefixed to all future stats
+func (r *Recorder) SetNamespace(ns string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Namespace = trimReserved(ns)
+}
+
+// WithNamespace returns a new Recorder, sharing this one's recording, whose
+// stats are namespaced under this one's namespace; see Godspeed.WithNamespace()
+func (r *Recorder) WithNamespace(ns string) *Recorder {
+	d := r.derive()
+
+	if ns = trimReserved(ns); len(d.Namespace) > 0 {
+		d.Namespace += "." + ns
+	} else {
+		d.Namespace = ns
+	}
+
+	return d
+}
+
+// WithTags returns a new Recorder, sharing this one's recording, which adds
+// tags to this one's tags; see Godspeed.WithTags()
+func (r *Recorder) WithTags(tags ...string) *Recorder {
+	d := r.derive()
+	d.Tags = mergeTags(d.Tags, tags)
+
+	return d
+}
+
+// derive returns a copy of this Recorder to be used by WithTags() and
+// WithNamespace()
+func (r *Recorder) derive() *Recorder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &Recorder{
+		Namespace: r.Namespace,
+		Tags:      r.Tags,
+		rec:       r.rec,
+		derived:   true,
+	}
+}
+
+// SetErrorHandler exists to match Godspeed; a Recorder has no background
+// errors, so fn is never called
+func (r *Recorder) SetErrorHandler(fn func(error)) {}
+
+// Flush is a no-op, as nothing is held back
+func (r *Recorder) Flush() error {
+	return nil
+}
+
+// Close stops recording; emitting after Close() returns ErrClosed. What was
+// recorded can still be queried. Like Godspeed, closing a Recorder made using
+// WithTags() or WithNamespace() does nothing.
+func (r *Recorder) Close() error {
+	if r.derived {
+		return nil
+	}
+
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	r.rec.closed = true
+
+	return nil
+}
+
+// Stats returns how many stats, events, and service checks were recorded;
+// nothing is ever written, so the other counts are always zero
+func (r *Recorder) Stats() Stats {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	return Stats{
+		Metrics:       uint64(len(r.rec.stats)),
+		Events:        uint64(len(r.rec.events)),
+		ServiceChecks: uint64(len(r.rec.serviceChecks)),
+	}
+}
+
+// Metrics returns every stat recorded, in the order they were recorded
+func (r *Recorder) Metrics() []RecordedStat {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	return append([]RecordedStat(nil), r.rec.stats...)
+}
+
+// Events returns every event recorded, in the order they were recorded
+func (r *Recorder) Events() []RecordedEvent {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	return append([]RecordedEvent(nil), r.rec.events...)
+}
+
+// ServiceChecks returns every service check recorded, in the order they were
+// recorded
+func (r *Recorder) ServiceChecks() []RecordedServiceCheck {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	return append([]RecordedServiceCheck(nil), r.rec.serviceChecks...)
+}
+
+// FindMetrics returns every stat recorded with the given full name (including
+// the namespace) that has all of the given tags, and possibly others
+func (r *Recorder) FindMetrics(name string, tags ...string) []RecordedStat {
+	var found []RecordedStat
+
+	for _, s := range r.Metrics() {
+		if s.Name == name && hasAllTags(s.Tags, tags) {
+			found = append(found, s)
+		}
+	}
+
+	return found
+}
+
+// Sum returns the total of the values of the stats with the given name and
+// tags; see FindMetrics(). This is most useful for counts.
+func (r *Recorder) Sum(name string, tags ...string) float64 {
+	var sum float64
+
+	for _, s := range r.FindMetrics(name, tags...) {
+		sum += s.Value
+	}
+
+	return sum
+}
+
+// Last returns the most recent stat with the given name and tags, and
+// whether there was one; see FindMetrics(). This is most useful for gauges.
+func (r *Recorder) Last(name string, tags ...string) (RecordedStat, bool) {
+	found := r.FindMetrics(name, tags...)
+
+	if len(found) == 0 {
+		return RecordedStat{}, false
+	}
+
+	return found[len(found)-1], true
+}
+
+// Reset forgets everything recorded so far
+func (r *Recorder) Reset() {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+
+	r.rec.stats = nil
+	r.rec.events = nil
+	r.rec.serviceChecks = nil
+}
+
+// statName returns the name of the stat, with the namespace prepended
+func (r *Recorder) statName(stat string) string {
+	r.mu.RLock()
+	ns := r.Namespace
+	r.mu.RUnlock()
+
+	if len(ns) > 0 {
+		return ns + "." + trimReserved(stat)
+	}
+
+	return trimReserved(stat)
+}
+
+// globalTags returns the current tags. AddTags() always replaces the slice,
+// so it can be used after the lock is released.
+func (r *Recorder) globalTags() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.Tags
+}
+
+// copyFields copies the optional fields of an event or service check, so
+// later changes by the caller aren't recorded
+func copyFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(fields))
+
+	for k, v := range fields {
+		c[k] = v
+	}
+
+	return c
+}
+
+// hasAllTags returns whether all of want are in tags
+func hasAllTags(tags, want []string) bool {
+	for _, w := range want {
+		if !containsTag(tags, w) {
+			return false
+		}
+	}
+
+	return true
+}
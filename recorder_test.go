@@ -0,0 +1,185 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type RecorderTestSuite struct {
+	r *godspeed.Recorder
+}
+
+var _ = Suite(&RecorderTestSuite{})
+
+func (t *RecorderTestSuite) SetUpTest(c *C) {
+	t.r = godspeed.NewRecorder()
+	t.r.SetNamespace("ns")
+	t.r.AddTags([]string{"env:test", "app"})
+}
+
+func (t *RecorderTestSuite) TestMetrics(c *C) {
+	ts := time.Unix(1657100430, 0)
+
+	c.Assert(t.r.Incr("test.incr", []string{"a"}), IsNil)
+	c.Assert(t.r.Decr("test.incr", []string{"app"}), IsNil)
+	c.Assert(t.r.Count("test.incr", 5, []string{"a", "b"}), IsNil)
+	c.Assert(t.r.Gauge("test.gauge", 1, nil), IsNil)
+	c.Assert(t.r.Gauge("test.gauge", 2, nil), IsNil)
+	c.Assert(t.r.Histogram("test.hist", 3, nil), IsNil)
+	c.Assert(t.r.Timing("test.timing", 4, nil), IsNil)
+	c.Assert(t.r.Set("test.set", 5, nil), IsNil)
+
+	// every stat is kept, regardless of the sample rate
+	c.Assert(t.r.Distribution("test.dist", 6, 0.0001, nil), IsNil)
+	c.Assert(t.r.GaugeWithTimestamp("test.gauge", 7, ts, nil), IsNil)
+	c.Assert(t.r.CountWithTimestamp("test.incr", 8, ts, nil), IsNil)
+
+	tags := []string{"env:test", "app"}
+
+	c.Check(t.r.Metrics(), DeepEquals, []godspeed.RecordedStat{
+		{Name: "ns.test.incr", Kind: "c", Value: 1, SampleRate: 1, Tags: []string{"env:test", "app", "a"}},
+		{Name: "ns.test.incr", Kind: "c", Value: -1, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.incr", Kind: "c", Value: 5, SampleRate: 1, Tags: []string{"env:test", "app", "a", "b"}},
+		{Name: "ns.test.gauge", Kind: "g", Value: 1, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.gauge", Kind: "g", Value: 2, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.hist", Kind: "h", Value: 3, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.timing", Kind: "ms", Value: 4, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.set", Kind: "s", Value: 5, SampleRate: 1, Tags: tags},
+		{Name: "ns.test.dist", Kind: "d", Value: 6, SampleRate: 0.0001, Tags: tags},
+		{Name: "ns.test.gauge", Kind: "g", Value: 7, SampleRate: 1, Tags: tags, Timestamp: ts},
+		{Name: "ns.test.incr", Kind: "c", Value: 8, SampleRate: 1, Tags: tags, Timestamp: ts},
+	})
+
+	//
+	// test the query helpers
+	//
+	c.Check(len(t.r.FindMetrics("ns.test.incr")), Equals, 4)
+	c.Check(len(t.r.FindMetrics("ns.test.incr", "a")), Equals, 2)
+	c.Check(len(t.r.FindMetrics("test.incr")), Equals, 0)
+	c.Check(t.r.Sum("ns.test.incr"), Equals, float64(13))
+	c.Check(t.r.Sum("ns.test.incr", "b"), Equals, float64(5))
+
+	last, ok := t.r.Last("ns.test.gauge")
+	c.Check(ok, Equals, true)
+	c.Check(last.Value, Equals, float64(7))
+
+	_, ok = t.r.Last("ns.test.gauge", "missing")
+	c.Check(ok, Equals, false)
+
+	c.Check(t.r.Stats(), DeepEquals, godspeed.Stats{Metrics: 11})
+
+	t.r.Reset()
+
+	c.Check(t.r.Metrics(), IsNil)
+	c.Check(t.r.Stats(), DeepEquals, godspeed.Stats{})
+
+	//
+	// test that it's validated like Godspeed
+	//
+	c.Check(t.r.SendWithTimestamp("test.hist", "h", 1, ts, nil), ErrorMatches, `stat kind "h" may not have a timestamp; only gauges \(g\) and counts \(c\) may`)
+	c.Check(t.r.GaugeWithTimestamp("test.gauge", 1, time.Unix(0, 0), nil), ErrorMatches, "invalid timestamp .*; must be after the Unix epoch")
+	c.Check(t.r.Metrics(), IsNil)
+}
+
+func (t *RecorderTestSuite) TestEventsAndServiceChecks(c *C) {
+	fields := map[string]string{"alert_type": "info"}
+
+	c.Assert(t.r.Event("title", "text\nmore", fields, []string{"a"}), IsNil)
+	c.Assert(t.r.ServiceCheck("svc", 2, nil, nil), IsNil)
+
+	// changes after the call aren't recorded
+	fields["alert_type"] = "error"
+
+	c.Check(t.r.Events(), DeepEquals, []godspeed.RecordedEvent{{
+		Title:  "title",
+		Text:   "text\nmore",
+		Fields: map[string]string{"alert_type": "info"},
+		Tags:   []string{"env:test", "app", "a"},
+	}})
+
+	c.Check(t.r.ServiceChecks(), DeepEquals, []godspeed.RecordedServiceCheck{{
+		Name:   "svc",
+		Status: 2,
+		Tags:   []string{"env:test", "app"},
+	}})
+
+	c.Check(t.r.Stats(), DeepEquals, godspeed.Stats{Events: 1, ServiceChecks: 1})
+
+	c.Check(errors.Is(t.r.Event("", "text", nil, nil), godspeed.ErrInvalidName), Equals, true)
+	c.Check(t.r.Event("title", "", nil, nil), Equals, godspeed.ErrEmptyBody)
+	c.Check(errors.Is(t.r.ServiceCheck("", 0, nil, nil), godspeed.ErrInvalidName), Equals, true)
+	c.Check(errors.Is(t.r.ServiceCheck("a|b", 0, nil, nil), godspeed.ErrInvalidName), Equals, true)
+	c.Check(errors.Is(t.r.ServiceCheck("svc", 4, nil, nil), godspeed.ErrInvalidStatus), Equals, true)
+
+	// the status is checked before the name's characters, like Godspeed
+	c.Check(errors.Is(t.r.ServiceCheck("a|b", 7, nil, nil), godspeed.ErrInvalidStatus), Equals, true)
+
+	c.Check(len(t.r.Events()), Equals, 1)
+	c.Check(len(t.r.ServiceChecks()), Equals, 1)
+}
+
+func (t *RecorderTestSuite) TestScoped(c *C) {
+	d := t.r.WithNamespace("http").WithTags("route:/")
+
+	c.Assert(d.Incr("requests", nil), IsNil)
+	c.Assert(d.Event("a", "b", nil, nil), IsNil)
+
+	// the original is unchanged
+	c.Assert(t.r.Incr("requests", nil), IsNil)
+
+	c.Check(t.r.Metrics(), DeepEquals, []godspeed.RecordedStat{
+		{Name: "ns.http.requests", Kind: "c", Value: 1, SampleRate: 1, Tags: []string{"env:test", "app", "route:/"}},
+		{Name: "ns.requests", Kind: "c", Value: 1, SampleRate: 1, Tags: []string{"env:test", "app"}},
+	})
+	c.Check(d.Events(), DeepEquals, t.r.Events())
+
+	c.Check(t.r.AddTag("app"), DeepEquals, []string{"env:test", "app"})
+
+	// closing a derived Recorder does nothing
+	c.Assert(d.Flush(), IsNil)
+	c.Assert(d.Close(), IsNil)
+	c.Assert(d.Incr("requests", nil), IsNil)
+
+	c.Assert(t.r.Close(), IsNil)
+
+	c.Check(t.r.Incr("requests", nil), Equals, godspeed.ErrClosed)
+	c.Check(d.Incr("requests", nil), Equals, godspeed.ErrClosed)
+	c.Check(d.Event("a", "b", nil, nil), Equals, godspeed.ErrClosed)
+	c.Check(d.ServiceCheck("svc", 0, nil, nil), Equals, godspeed.ErrClosed)
+
+	c.Check(len(t.r.Metrics()), Equals, 3)
+}
+
+func (t *RecorderTestSuite) TestConcurrent(c *C) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			d := t.r.WithTags("worker")
+
+			for j := 0; j < 100; j++ {
+				d.Incr("test.incr", nil)
+				t.r.AddTag("later")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	c.Check(t.r.Sum("ns.test.incr", "worker"), Equals, float64(400))
+	c.Check(t.r.Tags, DeepEquals, []string{"env:test", "app", "later"})
+}
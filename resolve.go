@@ -0,0 +1,203 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RefusedThreshold is the number of writes that have to be refused, since the
+// host was last looked up, before a ResolvingUDPTransport looks it up again
+// early. They aren't counted in a row, as the kernel only reports every other
+// write to a UDP port nobody is listening on as refused.
+const RefusedThreshold = 3
+
+// resolveUDPAddr looks up the address for a ResolvingUDPTransport; it's a
+// variable so the tests can pretend the address changed
+var resolveUDPAddr = net.ResolveUDPAddr
+
+// ResolvingUDPTransport is a Transport which writes to a UDP socket, like the
+// one created by New(), except it looks up its host again every interval.
+// When the host's address changes, a new socket is created for the new
+// address and swapped in, so that emissions follow the agent when its IP
+// changes (such as a Kubernetes service being recreated). The host is also
+// looked up again after RefusedThreshold writes are refused, which is how the
+// kernel reports that nothing is listening at the old address.
+//
+// Writes are never blocked by a lookup, and it's safe to write from multiple
+// goroutines while the socket is being swapped.
+type ResolvingUDPTransport struct {
+	host string
+	port int
+
+	// onError is called with errors looking up or connecting to the host in
+	// the background
+	onError func(error)
+
+	mu   sync.RWMutex
+	conn *net.UDPConn
+	addr string
+
+	refused int32
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// NewResolvingUDPTransport looks up host and connects to it, and then looks it
+// up again every interval. An interval less than 1 only looks it up again
+// when writes are refused.
+func NewResolvingUDPTransport(host string, port int, interval time.Duration) (*ResolvingUDPTransport, error) {
+	return newResolvingUDPTransport(host, port, interval, nil)
+}
+
+func newResolvingUDPTransport(host string, port int, interval time.Duration, onError func(error)) (*ResolvingUDPTransport, error) {
+	r := &ResolvingUDPTransport{
+		host:    host,
+		port:    port,
+		onError: onError,
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	if err := r.resolve(); err != nil {
+		return nil, err
+	}
+
+	go r.loop(interval)
+
+	return r, nil
+}
+
+// loop looks up the host every interval, and whenever it's kicked by
+// Write(), until Close() is called
+func (r *ResolvingUDPTransport) loop(interval time.Duration) {
+	defer close(r.done)
+
+	var tick <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-tick:
+		case <-r.kick:
+		}
+
+		if err := r.resolve(); err != nil && r.onError != nil {
+			r.onError(err)
+		}
+	}
+}
+
+// resolve looks up the host, and connects to it if its address has changed
+func (r *ResolvingUDPTransport) resolve() error {
+	addr, err := resolveUDPAddr("udp", net.JoinHostPort(r.host, strconv.Itoa(r.port)))
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	same := r.conn != nil && addr.String() == r.addr
+	r.mu.RUnlock()
+
+	atomic.StoreInt32(&r.refused, 0)
+
+	if same {
+		return nil
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.conn
+
+	if r.closed {
+		r.mu.Unlock()
+		return conn.Close()
+	}
+
+	r.conn, r.addr = conn, addr.String()
+	r.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	return nil
+}
+
+// Addr returns the address currently being written to
+func (r *ResolvingUDPTransport) Addr() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.addr
+}
+
+// Write writes a single datagram to the current address
+func (r *ResolvingUDPTransport) Write(b []byte) (int, error) {
+	r.mu.RLock()
+
+	if r.closed {
+		r.mu.RUnlock()
+		return 0, ErrClosed
+	}
+
+	n, err := r.conn.Write(b)
+	r.mu.RUnlock()
+
+	if errors.Is(err, syscall.ECONNREFUSED) && atomic.AddInt32(&r.refused, 1) >= RefusedThreshold {
+		// look the host up again without waiting for it
+		select {
+		case r.kick <- struct{}{}:
+		default:
+		}
+	}
+
+	return n, err
+}
+
+// Close stops looking up the host and closes the socket
+func (r *ResolvingUDPTransport) Close() error {
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.done
+
+	// resolve() won't swap in a new socket once closed is set
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.conn.Close()
+}
@@ -0,0 +1,212 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type ResolveTestSuite struct {
+	a, b *net.UDPConn
+
+	mu      sync.Mutex
+	addr    *net.UDPAddr
+	err     error
+	restore func()
+}
+
+var _ = Suite(&ResolveTestSuite{})
+
+func (t *ResolveTestSuite) SetUpTest(c *C) {
+	var err error
+
+	t.a, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	c.Assert(err, IsNil)
+
+	t.b, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	c.Assert(err, IsNil)
+
+	t.point(t.a.LocalAddr().(*net.UDPAddr), nil)
+
+	// every lookup returns whatever the test last pointed it at
+	t.restore = godspeed.SetResolveUDPAddr(func(network, address string) (*net.UDPAddr, error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		return t.addr, t.err
+	})
+}
+
+func (t *ResolveTestSuite) TearDownTest(c *C) {
+	t.restore()
+	t.a.Close()
+	t.b.Close()
+}
+
+// point changes what the host resolves to
+func (t *ResolveTestSuite) point(addr *net.UDPAddr, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.addr, t.err = addr, err
+}
+
+// read returns the next datagram received by l
+func read(c *C, l *net.UDPConn) string {
+	b := make([]byte, 1024)
+
+	l.SetReadDeadline(time.Now().Add(time.Second))
+
+	n, err := l.Read(b)
+	c.Assert(err, IsNil)
+
+	return string(b[:n])
+}
+
+// waitForAddr waits for r to be writing to addr
+func waitForAddr(r *godspeed.ResolvingUDPTransport, addr string) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if r.Addr() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *ResolveTestSuite) TestReResolve(c *C) {
+	r, err := godspeed.NewResolvingUDPTransport("statsd.example", 8125, 5*time.Millisecond)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	c.Check(r.Addr(), Equals, t.a.LocalAddr().String())
+
+	_, err = r.Write([]byte("test.incr:1|c"))
+	c.Assert(err, IsNil)
+	c.Check(read(c, t.a), Equals, "test.incr:1|c")
+
+	// the host moves, and the next lookup follows it
+	t.point(t.b.LocalAddr().(*net.UDPAddr), nil)
+	c.Assert(waitForAddr(r, t.b.LocalAddr().String()), Equals, true)
+
+	_, err = r.Write([]byte("test.gauge:42|g"))
+	c.Assert(err, IsNil)
+	c.Check(read(c, t.b), Equals, "test.gauge:42|g")
+}
+
+func (t *ResolveTestSuite) TestReResolveRefused(c *C) {
+	// nothing is listening at the first address
+	addr := t.a.LocalAddr().(*net.UDPAddr)
+	t.a.Close()
+
+	r, err := godspeed.NewResolvingUDPTransport("statsd.example", 8125, 0)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	t.point(t.b.LocalAddr().(*net.UDPAddr), nil)
+
+	// the host is only looked up again after enough writes are refused
+	var refused int
+
+	for deadline := time.Now().Add(time.Second); r.Addr() == addr.String() && time.Now().Before(deadline); {
+		if _, err := r.Write([]byte("test.incr:1|c")); err != nil {
+			refused++
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Check(refused >= godspeed.RefusedThreshold, Equals, true)
+	c.Assert(r.Addr(), Equals, t.b.LocalAddr().String())
+
+	_, err = r.Write([]byte("test.gauge:42|g"))
+	c.Assert(err, IsNil)
+	c.Check(read(c, t.b), Equals, "test.gauge:42|g")
+}
+
+func (t *ResolveTestSuite) TestWithReResolve(c *C) {
+	errs := make(chan error, 1)
+
+	g, err := godspeed.NewClient(
+		godspeed.WithAddress("statsd.example", 8125),
+		godspeed.WithReResolve(5*time.Millisecond),
+		godspeed.WithErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}),
+	)
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	r, ok := g.Transport.(*godspeed.ResolvingUDPTransport)
+	c.Assert(ok, Equals, true)
+	c.Check(g.Conn, IsNil)
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Check(read(c, t.a), Equals, "test.incr:1|c")
+
+	// failed lookups go to the error handler, and the old address is kept
+	t.point(nil, errors.New("no such host"))
+
+	select {
+	case err := <-errs:
+		c.Check(err, ErrorMatches, "no such host")
+	case <-time.After(time.Second):
+		c.Fatal("error handler was not called")
+	}
+
+	c.Check(r.Addr(), Equals, t.a.LocalAddr().String())
+
+	_, err = godspeed.NewClient(godspeed.WithReResolve(0))
+	c.Check(err, ErrorMatches, "re-resolve interval must be positive, got 0s")
+}
+
+func (t *ResolveTestSuite) TestConcurrentWrites(c *C) {
+	r, err := godspeed.NewResolvingUDPTransport("statsd.example", 8125, time.Millisecond)
+	c.Assert(err, IsNil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 200; j++ {
+				r.Write([]byte("test.incr:1|c"))
+			}
+		}()
+	}
+
+	// swap back and forth while the writes happen
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			t.point(t.b.LocalAddr().(*net.UDPAddr), nil)
+		} else {
+			t.point(t.a.LocalAddr().(*net.UDPAddr), nil)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	wg.Wait()
+
+	c.Assert(r.Close(), IsNil)
+	c.Assert(r.Close(), IsNil)
+
+	_, err = r.Write([]byte("test.incr:1|c"))
+	c.Check(err, Equals, godspeed.ErrClosed)
+}
@@ -25,16 +25,12 @@ var scMark = []string{"m", "d", "h"}
 //
 // http://docs.datadoghq.com/guides/dogstatsd/#service-checks
 func (g *Godspeed) ServiceCheck(name string, status int, fields map[string]string, tags []string) error {
-	if len(name) == 0 {
-		return fmt.Errorf("service name must have at least one character")
+	if err := validateServiceCheck(name, status); err != nil {
+		return err
 	}
 
-	if status < 0 || status > 3 {
-		return fmt.Errorf("unknown service status (%d); known values: 0,1,2,3", status)
-	}
-
-	if strings.ContainsAny("|", name) {
-		return fmt.Errorf("service name '%s' may not include pipe character ('|')", name)
+	if err := g.ready(); err != nil {
+		return err
 	}
 
 	var buf bytes.Buffer
@@ -49,19 +45,46 @@ func (g *Godspeed) ServiceCheck(name string, status int, fields map[string]strin
 		}
 	}
 
-	tags = uniqueTags(append(g.Tags, tags...))
+	// the merged tags are a new slice, so they can be cleaned up in place
+	tags = mergeTags(g.globalTags(), tags)
 
 	if len(tags) > 0 {
 		for i, v := range tags {
-			tags[i] = strings.Replace(v, "|", "", -1)
+			tags[i] = removePipes(v)
 		}
 		buf.WriteString(fmt.Sprintf("|#%s", strings.Join(tags, ",")))
 	}
 
-	if bufLen := buf.Len(); bufLen > MaxBytes {
-		return fmt.Errorf("error sending %s service check, packet larger than %d (%d)", name, MaxBytes, bufLen)
+	g.writeContainerID(&buf)
+
+	if bufLen, limit := buf.Len(), g.packetLimit(); bufLen > limit {
+		g.telemetry.add(counterTooLarge, 1)
+		return &PacketTooLargeError{Kind: "service_check", Name: name, Size: bufLen, Limit: limit}
+	}
+
+	_, err := g.write(buf.Bytes())
+
+	if err == nil {
+		g.telemetry.add(counterServiceChecks, 1)
 	}
 
-	_, err := g.Conn.Write(buf.Bytes())
 	return err
 }
+
+// validateServiceCheck returns the error for a service check name or status
+// that can't be sent, or nil if both are fine
+func validateServiceCheck(name string, status int) error {
+	if len(name) == 0 {
+		return &InvalidNameError{Kind: "service_check", Name: name}
+	}
+
+	if status < 0 || status > 3 {
+		return &InvalidStatusError{Name: name, Status: status}
+	}
+
+	if strings.ContainsAny("|", name) {
+		return &InvalidNameError{Kind: "service_check", Name: name}
+	}
+
+	return nil
+}
@@ -4,7 +4,10 @@
 
 package godspeed
 
-import "strings"
+import (
+	"strings"
+	"sync"
+)
 
 // stats names can't include :, |, or @
 var reservedReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_")
@@ -13,33 +16,70 @@ func trimReserved(s string) string {
 	return reservedReplacer.Replace(s)
 }
 
-// function to make sure tags are unique
-func uniqueTags(t []string) []string {
-	// if the tag slice is empty avoid allocation
-	if len(t) < 1 {
+// mergeSmallTags is the number of tags, in total, up to which mergeTags
+// looks for duplicates by scanning the tags it's already kept; beyond that it
+// uses a set from tagSetPool
+const mergeSmallTags = 32
+
+// tagSetPool holds sets for mergeTags to use, so that it doesn't need to
+// allocate one for every emission with lots of tags
+var tagSetPool = sync.Pool{
+	New: func() interface{} { return make(map[string]struct{}) },
+}
+
+// mergeTags returns the unique tags from global followed by those from
+// local, in the order they first appear. Neither slice is ever modified, and
+// the returned slice is always newly allocated so the caller is free to
+// modify it.
+func mergeTags(global, local []string) []string {
+	n := len(global) + len(local)
+
+	// if the tag slices are empty avoid allocation
+	if n == 0 {
 		return nil
 	}
 
-	// build a map to track which values we've seen
-	s := make(map[string]bool)
-
-	// loop over each string provided
-	// if the value is not in the map then replace
-	// the value at t[len(s)] so that we always have
-	// only unique tags at the beginning of the slice
-	for i, v := range t {
-		if _, x := s[v]; !x {
-			// only change the value if needed
-			if i != len(s) {
-				t[len(s)] = v
+	merged := make([]string, 0, n)
+
+	if n <= mergeSmallTags {
+		for _, tags := range [2][]string{global, local} {
+			for _, t := range tags {
+				if !containsTag(merged, t) {
+					merged = append(merged, t)
+				}
+			}
+		}
+
+		return merged
+	}
+
+	seen := tagSetPool.Get().(map[string]struct{})
+
+	for _, tags := range [2][]string{global, local} {
+		for _, t := range tags {
+			if _, ok := seen[t]; !ok {
+				seen[t] = struct{}{}
+				merged = append(merged, t)
 			}
+		}
+	}
+
+	for k := range seen {
+		delete(seen, k)
+	}
+
+	tagSetPool.Put(seen)
+
+	return merged
+}
 
-			s[v] = true
+// containsTag returns whether tag is in the slice
+func containsTag(tags []string, tag string) bool {
+	for _, v := range tags {
+		if v == tag {
+			return true
 		}
 	}
 
-	// based on the size of the map we know
-	// how many unique tags there were
-	// so return that slice
-	return []string(t[:len(s)])
+	return false
 }
@@ -0,0 +1,102 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type SharedTestSuite struct{}
+
+var _ = Suite(&SharedTestSuite{})
+
+func (t *SharedTestSuite) TestMergeTags(c *C) {
+	c.Check(godspeed.MergeTags(nil, nil), IsNil)
+
+	//
+	// test that duplicates are removed, keeping the order they first appear
+	//
+	global := []string{"a", "b", "a"}
+	local := []string{"c", "b", "d", "c"}
+
+	c.Check(godspeed.MergeTags(global, local), DeepEquals, []string{"a", "b", "c", "d"})
+	c.Check(godspeed.MergeTags(nil, local), DeepEquals, []string{"c", "b", "d"})
+	c.Check(godspeed.MergeTags(global, nil), DeepEquals, []string{"a", "b"})
+
+	// neither slice should be modified
+	c.Check(global, DeepEquals, []string{"a", "b", "a"})
+	c.Check(local, DeepEquals, []string{"c", "b", "d", "c"})
+
+	//
+	// test that spare capacity in the global tags is never written to
+	//
+	backing := make([]string, 3, 10)
+	copy(backing, []string{"a", "b", "c"})
+
+	merged := godspeed.MergeTags(backing, []string{"d", "e"})
+	merged[0] = "z"
+
+	c.Check(backing[:cap(backing)][3:5], DeepEquals, []string{"", ""})
+	c.Check(backing, DeepEquals, []string{"a", "b", "c"})
+
+	//
+	// test that lots of tags are merged the same way
+	//
+	var many, control []string
+
+	for i := 0; i < 100; i++ {
+		many = append(many, fmt.Sprintf("tag%d", i%70))
+
+		if i < 70 {
+			control = append(control, fmt.Sprintf("tag%d", i))
+		}
+	}
+
+	c.Check(godspeed.MergeTags(many[:50], many[50:]), DeepEquals, control)
+	c.Check(godspeed.MergeTags(many[:50], many[50:]), DeepEquals, control)
+}
+
+func (t *SharedTestSuite) TestTagAliasing(c *C) {
+	m := &memTransport{}
+	g := godspeed.NewWithTransport(m, false)
+
+	// global tags with spare capacity used to be written to by emissions
+	g.Tags = make([]string, 2, 64)
+	copy(g.Tags, []string{"global:1", "global:2"})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			local := []string{fmt.Sprintf("local:%d", i), "global:1"}
+
+			for j := 0; j < 50; j++ {
+				g.Incr("test.incr", local)
+				g.Event("a", "b", nil, local)
+				g.ServiceCheck("testSvc", 0, nil, local)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	c.Check(g.Tags, DeepEquals, []string{"global:1", "global:2"})
+
+	for _, w := range m.Writes() {
+		c.Check(strings.Count(w, "global:1"), Equals, 1)
+		c.Check(w, Matches, ".*#global:1,global:2,local:[0-7]")
+	}
+}
@@ -7,38 +7,110 @@ package godspeed
 import (
 	"bytes"
 	"fmt"
-	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Send is the function for emitting the metrics to statsd
 // It takes the name of the stat as a string, as well as the kind.
 // The kind is "g" for gauge, "c" for count, "ms" for timing, etc.
 // This returns any error hit during the flushing of the stat
+//
+// If aggregation has been enabled, kinds that are aggregated are held in
+// memory until the next flush. The sample rate is ignored for counts, gauges,
+// and sets that are aggregated.
 func (g *Godspeed) Send(stat, kind string, delta, sampleRate float64, tags []string) (err error) {
-	// if the connection hasn't been set up yet
-	if g.Conn == nil {
-		return fmt.Errorf("socket not created")
+	// if the connection hasn't been set up yet, or has been closed
+	if err := g.ready(); err != nil {
+		return err
 	}
 
-	// return if the sample rate is less than 1 and the random number is less than the sample rate
-	if sampleRate < 1 && rand.Float64() >= sampleRate {
+	// add any provided tags to the metric
+	tags = mergeTags(g.globalTags(), tags)
+
+	if g.aggregator != nil && g.aggregator.aggregates(kind) {
+		// kinds where every sample is kept still honor the sample rate
+		if samples(kind) && !g.sampled(sampleRate) {
+			return nil
+		}
+
+		g.aggregator.add(kind, g.statName(stat), strings.Join(tags, ","), delta, sampleRate)
+		g.telemetry.add(counterMetrics, 1)
 		return nil
 	}
 
-	var buffer bytes.Buffer
+	// return if the sample rate is less than 1 and the random number is not less than the sample rate
+	if !g.sampled(sampleRate) {
+		return nil
+	}
+
+	if err = g.sendLine(stat, g.statName(stat), strconv.FormatFloat(delta, 'f', -1, 64), kind, sampleRate, strings.Join(tags, ","), 0); err == nil {
+		g.telemetry.add(counterMetrics, 1)
+	}
+
+	return
+}
+
+// SendWithTimestamp is like Send() except the stat is recorded at the time
+// given, rather than when the agent receives it (DogStatsD protocol v1.3).
+// This is meant for backfilling stats, such as from batch jobs. Only gauges
+// ("g") and counts ("c") may have a timestamp, and the timestamp must be after
+// the Unix epoch. Timestamped stats are never sampled or aggregated.
+func (g *Godspeed) SendWithTimestamp(stat, kind string, delta float64, timestamp time.Time, tags []string) error {
+	if err := g.ready(); err != nil {
+		return err
+	}
+
+	if err := validateTimestamp(kind, timestamp); err != nil {
+		return err
+	}
+
+	tags = mergeTags(g.globalTags(), tags)
+
+	err := g.sendLine(stat, g.statName(stat), strconv.FormatFloat(delta, 'f', -1, 64), kind, 1, strings.Join(tags, ","), timestamp.Unix())
+
+	if err == nil {
+		g.telemetry.add(counterMetrics, 1)
+	}
+
+	return err
+}
 
-	// if we have a namespace write it to the byte buffer
-	if len(g.Namespace) > 0 {
-		buffer.WriteString(g.Namespace)
-		buffer.WriteByte('.')
+// validateTimestamp returns the error for a stat kind and timestamp that
+// can't be sent together, or nil if they can
+func validateTimestamp(kind string, timestamp time.Time) error {
+	if kind != "g" && kind != "c" {
+		return fmt.Errorf("stat kind %q may not have a timestamp; only gauges (g) and counts (c) may", kind)
 	}
 
+	if timestamp.Unix() < 1 {
+		return fmt.Errorf("invalid timestamp %v; must be after the Unix epoch", timestamp)
+	}
+
+	return nil
+}
+
+// statName returns the name of the stat, with the namespace prepended
+func (g *Godspeed) statName(stat string) string {
+	if ns := g.namespace(); len(ns) > 0 {
+		return ns + "." + trimReserved(stat)
+	}
+
+	return trimReserved(stat)
+}
+
+// sendLine builds a single stat line and writes it. name is the full name of
+// the stat, values is the already-formatted value(s), and tags is the comma
+// separated list of tags. timestamp is a Unix timestamp, or 0 for none. stat is
+// only used for the error message.
+func (g *Godspeed) sendLine(stat, name, values, kind string, sampleRate float64, tags string, timestamp int64) (err error) {
+	var buffer bytes.Buffer
+
 	// write the name of the metric to the byte buffer as well as the metric itself
-	buffer.WriteString(trimReserved(stat))
+	buffer.WriteString(name)
 	buffer.WriteByte(':')
-	buffer.WriteString(strconv.FormatFloat(delta, 'f', -1, 64))
+	buffer.WriteString(values)
 	buffer.WriteByte('|')
 	buffer.WriteString(kind)
 
@@ -48,23 +120,32 @@ func (g *Godspeed) Send(stat, kind string, delta, sampleRate float64, tags []str
 		buffer.WriteString(strconv.FormatFloat(sampleRate, 'f', -1, 64))
 	}
 
-	// add any provided tags to the metric
-	tags = uniqueTags(append(g.Tags, tags...))
 	if len(tags) > 0 {
 		buffer.WriteString("|#")
-		buffer.WriteString(strings.Join(tags, ","))
+		buffer.WriteString(tags)
+	}
+
+	g.writeContainerID(&buffer)
+
+	if timestamp > 0 {
+		buffer.WriteString("|T")
+		buffer.WriteString(strconv.FormatInt(timestamp, 10))
 	}
 
 	// this handles the logic for truncation
 	// if the buffer length is smaller than the max, just write it
 	// else if AutoTruncate is enabled truncate/write the bytes
 	// else generate an error to return
-	if buffer.Len() <= MaxBytes {
-		_, err = g.Conn.Write(buffer.Bytes())
+	limit := g.packetLimit()
+
+	if buffer.Len() <= limit {
+		_, err = g.write(buffer.Bytes())
 	} else if g.AutoTruncate {
-		_, err = g.Conn.Write(buffer.Bytes()[0:MaxBytes])
+		_, err = g.write(buffer.Bytes()[0:limit])
+		g.telemetry.add(counterTruncated, 1)
 	} else {
-		err = fmt.Errorf("error sending %v, packet larger than %d (%d)", stat, MaxBytes, buffer.Len())
+		err = &PacketTooLargeError{Kind: "stat", Name: stat, Size: buffer.Len(), Limit: limit}
+		g.telemetry.add(counterTooLarge, 1)
 	}
 
 	return
@@ -72,37 +153,57 @@ func (g *Godspeed) Send(stat, kind string, delta, sampleRate float64, tags []str
 
 // Count wraps Send() and simplifies the interface for Count stats
 func (g *Godspeed) Count(stat string, count float64, tags []string) error {
-	return g.Send(stat, "c", count, 1, append(g.Tags, tags...))
+	return g.Send(stat, "c", count, 1, tags)
 }
 
 // Incr wraps Send() and simplifies the interface for incrementing a counter
 // It only takes the name of the stat, and tags
 func (g *Godspeed) Incr(stat string, tags []string) error {
-	return g.Count(stat, 1, append(g.Tags, tags...))
+	return g.Count(stat, 1, tags)
 }
 
 // Decr wraps Send() and simplifies the interface for decrementing a counter
 // It only takes the name of the stat, and tags
 func (g *Godspeed) Decr(stat string, tags []string) error {
-	return g.Count(stat, -1, append(g.Tags, tags...))
+	return g.Count(stat, -1, tags)
+}
+
+// CountWithTimestamp wraps SendWithTimestamp() and simplifies the interface
+// for Count stats recorded at a specific time
+func (g *Godspeed) CountWithTimestamp(stat string, count float64, timestamp time.Time, tags []string) error {
+	return g.SendWithTimestamp(stat, "c", count, timestamp, tags)
 }
 
 // Gauge wraps Send() and simplifies the interface for Gauge stats
 func (g *Godspeed) Gauge(stat string, value float64, tags []string) error {
-	return g.Send(stat, "g", value, 1, append(g.Tags, tags...))
+	return g.Send(stat, "g", value, 1, tags)
+}
+
+// GaugeWithTimestamp wraps SendWithTimestamp() and simplifies the interface
+// for Gauge stats recorded at a specific time
+func (g *Godspeed) GaugeWithTimestamp(stat string, value float64, timestamp time.Time, tags []string) error {
+	return g.SendWithTimestamp(stat, "g", value, timestamp, tags)
 }
 
 // Histogram wraps Send() and simplifies the interface for Histogram stats
 func (g *Godspeed) Histogram(stat string, value float64, tags []string) error {
-	return g.Send(stat, "h", value, 1, append(g.Tags, tags...))
+	return g.Send(stat, "h", value, 1, tags)
 }
 
 // Timing wraps Send() and simplifies the interface for Timing stats
 func (g *Godspeed) Timing(stat string, value float64, tags []string) error {
-	return g.Send(stat, "ms", value, 1, append(g.Tags, tags...))
+	return g.Send(stat, "ms", value, 1, tags)
+}
+
+// Distribution wraps Send() and simplifies the interface for Distribution stats.
+// Distributions are aggregated by Datadog across all hosts, rather than by the
+// local agent, so percentiles are global. The sampleRate works the same as it
+// does for Send().
+func (g *Godspeed) Distribution(stat string, value, sampleRate float64, tags []string) error {
+	return g.Send(stat, "d", value, sampleRate, tags)
 }
 
 // Set wraps Send() and simplifies the interface for Timing stats
 func (g *Godspeed) Set(stat string, value float64, tags []string) error {
-	return g.Send(stat, "s", value, 1, append(g.Tags, tags...))
+	return g.Send(stat, "s", value, 1, tags)
 }
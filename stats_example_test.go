@@ -67,3 +67,16 @@ func ExampleGodspeed_Gauge() {
 		// handle error
 	}
 }
+
+func ExampleGodspeed_Distribution() {
+	g, _ := godspeed.NewDefault()
+
+	defer g.Conn.Close()
+
+	// emit every request's latency, with percentiles calculated across all hosts
+	err := g.Distribution("example.request.latency", 42.5, 1, []string{"endpoint:home"})
+
+	if err != nil {
+		// handle error
+	}
+}
@@ -6,6 +6,7 @@ package godspeed_test
 
 import (
 	"math/rand"
+	"time"
 
 	"github.com/PagerDuty/godspeed"
 
@@ -184,6 +185,45 @@ func (t *TestSuite) TestDecr(c *C) {
 	c.Check(string(a), Equals, "test.decr:-1|c")
 }
 
+func (t *TestSuite) TestSendWithTimestamp(c *C) {
+	ts := time.Unix(1431484263, 0)
+
+	err := t.g.SendWithTimestamp("test.gauge", "g", 42, ts, []string{"tag:a"})
+	c.Assert(err, IsNil)
+
+	a, ok := <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "test.gauge:42|g|#tag:a|T1431484263")
+
+	err = t.g.CountWithTimestamp("test.count", 5, ts, nil)
+	c.Assert(err, IsNil)
+
+	a, ok = <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "test.count:5|c|T1431484263")
+
+	err = t.g.GaugeWithTimestamp("test.gauge", 1.5, ts, nil)
+	c.Assert(err, IsNil)
+
+	a, ok = <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "test.gauge:1.5|g|T1431484263")
+
+	//
+	// test that invalid kinds and timestamps trigger an error
+	//
+	err = t.g.SendWithTimestamp("test.hist", "h", 1, ts, nil)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, `stat kind "h" may not have a timestamp; only gauges (g) and counts (c) may`)
+
+	err = t.g.GaugeWithTimestamp("test.gauge", 1, time.Time{}, nil)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "invalid timestamp .*; must be after the Unix epoch")
+
+	err = t.g.CountWithTimestamp("test.count", 1, time.Unix(0, 0), nil)
+	c.Assert(err, Not(IsNil))
+}
+
 func (t *TestSuite) TestGauge(c *C) {
 	err := t.g.Gauge("test.gauge", 42, nil)
 	c.Assert(err, IsNil)
@@ -211,6 +251,33 @@ func (t *TestSuite) TestTiming(c *C) {
 	c.Check(string(a), Equals, "test.timing:2054|ms")
 }
 
+func (t *TestSuite) TestDistribution(c *C) {
+	err := t.g.Distribution("test.dist", 128, 1, nil)
+	c.Assert(err, IsNil)
+
+	a, ok := <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "test.dist:128|d")
+
+	m := &memTransport{}
+	g, err := godspeed.NewClient(godspeed.WithTransport(m), alwaysSample)
+	c.Assert(err, IsNil)
+
+	c.Assert(g.Distribution("test.dist", 128, 0.99, []string{"tag:a"}), IsNil)
+	c.Check(m.Writes(), DeepEquals, []string{"test.dist:128|d|@0.99|#tag:a"})
+
+	// a sample rate of 0 should never emit
+	err = t.g.Distribution("test.dist", 128, 0, nil)
+	c.Assert(err, IsNil)
+
+	err = t.g.Distribution("test.dist", 256, 1, nil)
+	c.Assert(err, IsNil)
+
+	a, ok = <-t.o
+	c.Assert(ok, Equals, true)
+	c.Check(string(a), Equals, "test.dist:256|d")
+}
+
 func (t *TestSuite) TestSet(c *C) {
 	err := t.g.Set("test.set", 10, nil)
 	c.Assert(err, IsNil)
@@ -0,0 +1,209 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWriteTimeout is how long a StreamTransport waits for a write to
+	// the peer before giving up on the connection
+	DefaultWriteTimeout = 100 * time.Millisecond
+
+	// DefaultDialTimeout is how long a StreamTransport waits to connect
+	DefaultDialTimeout = time.Second
+
+	// DefaultMinBackoff is how long a StreamTransport waits before trying to
+	// reconnect the first time after losing its connection
+	DefaultMinBackoff = 100 * time.Millisecond
+
+	// DefaultMaxBackoff is the longest a StreamTransport waits between tries
+	// to reconnect
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// StreamTransport is a Transport which writes to a stream socket, such as a
+// TCP statsd relay or the agent's unix stream socket. As a stream has no
+// datagram boundaries, each datagram is framed: over TCP it's followed by a
+// newline, and over a unix socket it's prefixed by its length as a 32-bit
+// little-endian integer, which is what the agent expects.
+//
+// If a write fails or times out, the connection is dropped and writes return
+// an error until it's reconnected. Reconnecting is tried on the next write,
+// waiting longer after each failure (up to MaxBackoff) so that a dead peer
+// doesn't get hammered. A write spends at most the DialTimeout reconnecting
+// and the WriteTimeout writing, but writes are serialized, so concurrent
+// writers also wait for those ahead of them, including any reconnect they
+// do.
+//
+// The exported fields may be changed after NewStreamTransport() returns, but
+// before the StreamTransport is used.
+type StreamTransport struct {
+	// WriteTimeout is the deadline for writing a single datagram
+	WriteTimeout time.Duration
+
+	// DialTimeout is the deadline for reconnecting
+	DialTimeout time.Duration
+
+	// MinBackoff and MaxBackoff are the shortest and longest times to wait
+	// between tries to reconnect
+	MinBackoff, MaxBackoff time.Duration
+
+	network string
+	address string
+
+	mu      sync.Mutex
+	conn    net.Conn
+	frame   []byte
+	backoff time.Duration
+	retry   time.Time
+	lastErr error
+	closed  bool
+}
+
+// NewStreamTransport connects to address over network, which must be "tcp",
+// "tcp4", "tcp6", or "unix". An error is returned if the first connection
+// fails; after that, lost connections are reconnected automatically.
+func NewStreamTransport(network, address string) (*StreamTransport, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported stream network %q; must be tcp, tcp4, tcp6, or unix", network)
+	}
+
+	s := &StreamTransport{
+		WriteTimeout: DefaultWriteTimeout,
+		DialTimeout:  DefaultDialTimeout,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		network:      network,
+		address:      address,
+	}
+
+	conn, err := net.DialTimeout(network, address, s.DialTimeout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn = conn
+
+	return s, nil
+}
+
+// NewStream is like New() except it emits over a stream socket using a
+// StreamTransport; see NewStreamTransport() for the networks supported.
+func NewStream(network, address string, autoTruncate bool) (g *Godspeed, err error) {
+	g, err = NewClient(WithStream(network, address), WithAutoTruncate(autoTruncate))
+	return
+}
+
+// Write frames b and writes it to the peer, reconnecting first if needed
+func (s *StreamTransport) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	if s.conn == nil {
+		if err := s.reconnect(); err != nil {
+			return 0, err
+		}
+	}
+
+	s.frame = s.appendFrame(s.frame[:0], b)
+
+	if s.WriteTimeout > 0 {
+		s.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+
+	// a partial write leaves the stream in the middle of a frame, so the
+	// connection can't be used for anything else
+	if _, err := s.conn.Write(s.frame); err != nil {
+		s.drop(err)
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// appendFrame appends b to dst, framed for the network
+func (s *StreamTransport) appendFrame(dst, b []byte) []byte {
+	if s.network == "unix" {
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(b)))
+
+		return append(append(dst, size[:]...), b...)
+	}
+
+	return append(append(dst, b...), '\n')
+}
+
+// reconnect dials the peer, unless it's too soon since the last try;
+// s.mu must be held
+func (s *StreamTransport) reconnect() error {
+	if time.Now().Before(s.retry) {
+		return fmt.Errorf("reconnecting to %s %s: %v", s.network, s.address, s.lastErr)
+	}
+
+	conn, err := net.DialTimeout(s.network, s.address, s.DialTimeout)
+
+	if err != nil {
+		s.lastErr = err
+
+		// wait twice as long each time, between MinBackoff and MaxBackoff
+		if s.backoff *= 2; s.backoff < s.MinBackoff {
+			s.backoff = s.MinBackoff
+		} else if s.backoff > s.MaxBackoff {
+			s.backoff = s.MaxBackoff
+		}
+
+		s.retry = time.Now().Add(s.backoff)
+
+		return err
+	}
+
+	s.conn = conn
+	s.backoff = 0
+	s.lastErr = nil
+
+	return nil
+}
+
+// drop closes the connection after err, so the next write reconnects;
+// s.mu must be held
+func (s *StreamTransport) drop(err error) {
+	s.conn.Close()
+	s.conn = nil
+	s.lastErr = err
+}
+
+// Close closes the connection; later writes return ErrClosed
+func (s *StreamTransport) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
@@ -0,0 +1,213 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type StreamTestSuite struct {
+	l net.Listener
+}
+
+var _ = Suite(&StreamTestSuite{})
+
+func (t *StreamTestSuite) SetUpTest(c *C) {
+	var err error
+
+	t.l, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+}
+
+func (t *StreamTestSuite) TearDownTest(c *C) {
+	t.l.Close()
+}
+
+// accept returns the next connection to the listener
+func (t *StreamTestSuite) accept(c *C) net.Conn {
+	conn, err := t.l.Accept()
+	c.Assert(err, IsNil)
+
+	return conn
+}
+
+func (t *StreamTestSuite) TestNewStream(c *C) {
+	g, err := godspeed.NewStream("tcp", t.l.Addr().String(), false)
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	conn := t.accept(c)
+	defer conn.Close()
+
+	c.Check(g.Conn, IsNil)
+
+	g.EnableBuffering(0, 0)
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(g.Gauge("test.gauge", 42, nil), IsNil)
+	c.Assert(g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+	c.Assert(g.Flush(), IsNil)
+
+	r := bufio.NewReader(conn)
+
+	for _, line := range []string{"test.incr:1|c", "test.gauge:42|g", "_sc|testSvc|0"} {
+		l, err := r.ReadString('\n')
+		c.Assert(err, IsNil)
+		c.Check(l, Equals, line+"\n")
+	}
+}
+
+func (t *StreamTestSuite) TestUnixStream(c *C) {
+	path := filepath.Join(c.MkDir(), "dsd.socket")
+
+	l, err := net.Listen("unix", path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+
+	g, err := godspeed.NewClient(godspeed.WithStream("unix", path))
+	c.Assert(err, IsNil)
+	defer g.Close()
+
+	conn, err := l.Accept()
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	c.Assert(g.Incr("test.incr", nil), IsNil)
+	c.Assert(g.Gauge("test.gauge", 42, nil), IsNil)
+
+	for _, packet := range []string{"test.incr:1|c", "test.gauge:42|g"} {
+		var size uint32
+		c.Assert(binary.Read(conn, binary.LittleEndian, &size), IsNil)
+		c.Assert(int(size), Equals, len(packet))
+
+		b := make([]byte, size)
+		_, err := io.ReadFull(conn, b)
+		c.Assert(err, IsNil)
+		c.Check(string(b), Equals, packet)
+	}
+}
+
+func (t *StreamTestSuite) TestReconnect(c *C) {
+	s, err := godspeed.NewStreamTransport("tcp", t.l.Addr().String())
+	c.Assert(err, IsNil)
+	defer s.Close()
+
+	// the peer going away shows up as an error once the kernel notices
+	t.accept(c).Close()
+
+	deadline := time.Now().Add(time.Second)
+
+	for err == nil && time.Now().Before(deadline) {
+		_, err = s.Write([]byte("test.incr:1|c"))
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Assert(err, NotNil)
+
+	// the next write reconnects
+	n, err := s.Write([]byte("test.gauge:42|g"))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 15)
+
+	conn := t.accept(c)
+	defer conn.Close()
+
+	l, err := bufio.NewReader(conn).ReadString('\n')
+	c.Assert(err, IsNil)
+	c.Check(l, Equals, "test.gauge:42|g\n")
+}
+
+func (t *StreamTestSuite) TestReconnectBackoff(c *C) {
+	s, err := godspeed.NewStreamTransport("tcp", t.l.Addr().String())
+	c.Assert(err, IsNil)
+	defer s.Close()
+
+	s.MinBackoff = time.Hour
+
+	t.accept(c).Close()
+	t.l.Close()
+
+	deadline := time.Now().Add(time.Second)
+
+	for err == nil && time.Now().Before(deadline) {
+		_, err = s.Write([]byte("test.incr:1|c"))
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Assert(err, NotNil)
+
+	// the connection was dropped, so this tries to reconnect and fails
+	_, err = s.Write([]byte("test.incr:1|c"))
+	c.Assert(err, NotNil)
+	c.Check(strings.HasPrefix(err.Error(), "reconnecting"), Equals, false)
+
+	// and this one doesn't try again until the backoff has passed
+	_, err = s.Write([]byte("test.incr:1|c"))
+	c.Check(err, ErrorMatches, "reconnecting to tcp 127.0.0.1:[0-9]+: .*connection refused")
+}
+
+func (t *StreamTestSuite) TestWriteTimeout(c *C) {
+	s, err := godspeed.NewStreamTransport("tcp", t.l.Addr().String())
+	c.Assert(err, IsNil)
+	defer s.Close()
+
+	s.WriteTimeout = 10 * time.Millisecond
+
+	// the peer never reads, so the socket buffers eventually fill up
+	conn := t.accept(c)
+	defer conn.Close()
+
+	b := make([]byte, 65536)
+	deadline := time.Now().Add(5 * time.Second)
+
+	for err == nil && time.Now().Before(deadline) {
+		_, err = s.Write(b)
+	}
+
+	c.Assert(err, NotNil)
+
+	ne, ok := err.(net.Error)
+	c.Assert(ok, Equals, true)
+	c.Check(ne.Timeout(), Equals, true)
+}
+
+func (t *StreamTestSuite) TestClose(c *C) {
+	s, err := godspeed.NewStreamTransport("tcp", t.l.Addr().String())
+	c.Assert(err, IsNil)
+
+	c.Assert(s.Close(), IsNil)
+	c.Assert(s.Close(), IsNil)
+
+	_, err = s.Write([]byte("test.incr:1|c"))
+	c.Check(err, Equals, godspeed.ErrClosed)
+}
+
+func (t *StreamTestSuite) TestNewStreamTransportErrors(c *C) {
+	_, err := godspeed.NewStreamTransport("udp", t.l.Addr().String())
+	c.Check(err, ErrorMatches, `unsupported stream network "udp"; must be tcp, tcp4, tcp6, or unix`)
+
+	addr := t.l.Addr().String()
+	t.l.Close()
+
+	_, err = godspeed.NewStreamTransport("tcp", addr)
+	c.Check(err, ErrorMatches, ".*connection refused")
+
+	_, err = godspeed.NewClient(godspeed.WithStream("tcp", ""))
+	c.Check(err, ErrorMatches, "stream address must not be empty")
+
+	_, err = godspeed.NewClient(godspeed.WithStream("udp", addr))
+	c.Check(err, ErrorMatches, `unsupported stream network "udp"; must be tcp, tcp4, tcp6, or unix`)
+}
@@ -0,0 +1,277 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Version is the version of this package, included in the telemetry tags.
+// It's "dev" unless set when building, such as with
+// -ldflags "-X github.com/PagerDuty/godspeed.Version=1.2.0".
+var Version = "dev"
+
+// telemetryPrefix is the prefix of the telemetry stat names, the same as
+// the official Datadog clients
+const telemetryPrefix = "datadog.dogstatsd.client."
+
+// counter is one of the telemetry counters
+type counter int
+
+const (
+	counterMetrics counter = iota
+	counterEvents
+	counterServiceChecks
+	counterPackets
+	counterBytes
+	counterWriteErrors
+	counterBytesDropped
+	counterTooLarge
+	counterTruncated
+	counterQueueDropped
+
+	numCounters
+)
+
+// counterNames are the stat names the counters are emitted as
+var counterNames = [numCounters]string{
+	counterMetrics:       "metrics",
+	counterEvents:        "events",
+	counterServiceChecks: "service_checks",
+	counterPackets:       "packets_sent",
+	counterBytes:         "bytes_sent",
+	counterWriteErrors:   "packets_dropped_writer",
+	counterBytesDropped:  "bytes_dropped_writer",
+	counterTooLarge:      "packets_too_large",
+	counterTruncated:     "packets_truncated",
+	counterQueueDropped:  "packets_dropped_queue",
+}
+
+// Stats is a snapshot of what a Godspeed instance has emitted, and failed
+// to emit, since it was created. Instances made using WithTags() or
+// WithNamespace() share their counts with the instance they came from.
+type Stats struct {
+	// Metrics is the number of stats sent, or added to the aggregates
+	Metrics uint64
+
+	// Events is the number of events sent
+	Events uint64
+
+	// ServiceChecks is the number of service checks sent
+	ServiceChecks uint64
+
+	// Packets is the number of datagrams written to the Transport
+	Packets uint64
+
+	// Bytes is the number of bytes written to the Transport
+	Bytes uint64
+
+	// WriteErrors is the number of datagrams the Transport returned an
+	// error for
+	WriteErrors uint64
+
+	// BytesDropped is the number of bytes in datagrams the Transport
+	// returned an error for
+	BytesDropped uint64
+
+	// TooLarge is the number of emissions not sent because they were larger
+	// than the packet limit
+	TooLarge uint64
+
+	// Truncated is the number of stats truncated to fit in the packet limit
+	// because AutoTruncate was set
+	Truncated uint64
+
+	// QueueDropped is the number of AsyncGodspeed emissions dropped because
+	// the queue was full or closed
+	QueueDropped uint64
+}
+
+// telemetry holds the counters for a Godspeed instance, and those derived
+// from it. All of its methods are safe to call on a nil *telemetry.
+type telemetry struct {
+	counters [numCounters]uint64
+
+	// last is the value of each counter when they were last emitted; it's
+	// only used by the emit loop
+	last [numCounters]uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newTelemetry() *telemetry {
+	t := &telemetry{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	// there's no emit loop until EnableTelemetry() is called
+	close(t.done)
+
+	return t
+}
+
+// add adds n to counter c
+func (t *telemetry) add(c counter, n uint64) {
+	if t != nil {
+		atomic.AddUint64(&t.counters[c], n)
+	}
+}
+
+// snapshot returns the current value of each counter
+func (t *telemetry) snapshot() (s [numCounters]uint64) {
+	if t == nil {
+		return
+	}
+
+	for i := range s {
+		s[i] = atomic.LoadUint64(&t.counters[i])
+	}
+
+	return
+}
+
+// start starts emitting the counters every interval
+func (t *telemetry) start(interval time.Duration, g *Godspeed) {
+	t.halt()
+
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+
+	go t.loop(interval, g)
+}
+
+// loop emits the counters every interval until halt() is called
+func (t *telemetry) loop(interval time.Duration, g *Godspeed) {
+	defer close(t.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			g.handleError(t.emit(g))
+		}
+	}
+}
+
+// halt stops the emit loop, if it's running
+func (t *telemetry) halt() {
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+
+	<-t.done
+}
+
+// emit sends how much each counter has changed since the last emission, as
+// counts. The namespace and global tags aren't used, so that the stats are
+// the same for every client.
+func (t *telemetry) emit(g *Godspeed) error {
+	var err error
+
+	current := t.snapshot()
+	tags := strings.Join(telemetryTags(g), ",")
+
+	// counts that haven't changed are sent as zeroes, like the official
+	// clients, so that the stats don't appear to stop
+	for i, v := range current {
+		delta := v - t.last[i]
+		t.last[i] = v
+
+		name := telemetryPrefix + counterNames[i]
+
+		if serr := g.sendLine(name, name, strconv.FormatUint(delta, 10), "c", 1, tags, 0); err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// telemetryTags returns the tags the telemetry stats are sent with
+func telemetryTags(g *Godspeed) []string {
+	return []string{
+		"client:go",
+		"client_version:" + Version,
+		"client_transport:" + transportName(g),
+	}
+}
+
+// transportName returns the name of the Transport for the telemetry tags
+func transportName(g *Godspeed) string {
+	switch t := g.Transport.(type) {
+	case *net.UDPConn, *ResolvingUDPTransport:
+		return "udp"
+	case *net.UnixConn:
+		return "uds"
+	case *StreamTransport:
+		if t.network == "unix" {
+			return "uds-stream"
+		}
+
+		return "tcp"
+	case nil:
+		if g.Conn != nil {
+			return "udp"
+		}
+	}
+
+	return "custom"
+}
+
+// Stats returns a snapshot of what this instance has emitted, and failed to
+// emit, since it was created. The counts are always kept; EnableTelemetry()
+// also emits them to the agent.
+func (g *Godspeed) Stats() Stats {
+	s := g.telemetry.snapshot()
+
+	return Stats{
+		Metrics:       s[counterMetrics],
+		Events:        s[counterEvents],
+		ServiceChecks: s[counterServiceChecks],
+		Packets:       s[counterPackets],
+		Bytes:         s[counterBytes],
+		WriteErrors:   s[counterWriteErrors],
+		BytesDropped:  s[counterBytesDropped],
+		TooLarge:      s[counterTooLarge],
+		Truncated:     s[counterTruncated],
+		QueueDropped:  s[counterQueueDropped],
+	}
+}
+
+// EnableTelemetry has Godspeed emit the counts from Stats() every interval,
+// as datadog.dogstatsd.client.* counts like the official Datadog clients.
+// They're tagged with client:go, client_version:<Version>, and
+// client_transport:<udp, uds, tcp, uds-stream, or custom>, and aren't
+// namespaced or given the global tags.
+// Each emission is how much the count has changed since the last one.
+//
+// This should be called before the Godspeed instance is used, and does
+// nothing if interval is less than 1 or the instance wasn't built using one
+// of the constructors.
+func (g *Godspeed) EnableTelemetry(interval time.Duration) {
+	if interval <= 0 || g.telemetry == nil {
+		return
+	}
+
+	g.telemetry.start(interval, g)
+}
+
+// Stats is identical to that within the Godspeed client, and includes the
+// number of emissions dropped from the queue
+func (a *AsyncGodspeed) Stats() Stats {
+	return a.Godspeed.Stats()
+}
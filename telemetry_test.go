@@ -0,0 +1,132 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+type TelemetryTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&TelemetryTestSuite{})
+
+func (t *TelemetryTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+	t.g = godspeed.NewWithTransport(t.m, false)
+}
+
+func (t *TelemetryTestSuite) TestStats(c *C) {
+	c.Check(t.g.Stats(), DeepEquals, godspeed.Stats{})
+
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+	c.Assert(t.g.WithTags("derived").Gauge("test.gauge", 42, nil), IsNil)
+	c.Assert(t.g.Event("a", "b", nil, nil), IsNil)
+	c.Assert(t.g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+
+	// not sampled, so not sent
+	c.Assert(t.g.Send("test.sampled", "c", 1, 0, nil), IsNil)
+
+	long := strings.Repeat("a", godspeed.MaxBytes)
+	c.Check(t.g.Incr(long, nil), NotNil)
+
+	t.g.AutoTruncate = true
+	c.Assert(t.g.Incr(long, nil), IsNil)
+
+	c.Check(t.g.Stats(), DeepEquals, godspeed.Stats{
+		Metrics:       3,
+		Events:        1,
+		ServiceChecks: 1,
+		Packets:       5,
+		Bytes:         uint64(13 + 24 + 11 + 13 + godspeed.MaxBytes),
+		TooLarge:      1,
+		Truncated:     1,
+	})
+}
+
+func (t *TelemetryTestSuite) TestStatsWriteErrors(c *C) {
+	g := godspeed.NewWithTransport(errTransport{}, false)
+
+	c.Check(g.Incr("test.incr", nil), NotNil)
+	c.Check(g.Event("a", "b", nil, nil), NotNil)
+
+	c.Check(g.Stats(), DeepEquals, godspeed.Stats{
+		WriteErrors:  2,
+		BytesDropped: 13 + 11,
+	})
+}
+
+func (t *TelemetryTestSuite) TestStatsQueueDropped(c *C) {
+	gt := newGateTransport()
+	a := godspeed.NewAsyncWithQueue(godspeed.NewWithTransport(gt, false), 1, 1, false)
+
+	a.Incr("test.incr", nil, nil)
+	<-gt.waiting
+
+	a.Incr("test.incr", nil, nil)
+	a.Incr("test.incr", nil, nil)
+	a.Incr("test.incr", nil, nil)
+
+	close(gt.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	c.Assert(a.Close(ctx), IsNil)
+
+	s := a.Stats()
+	c.Check(s.Metrics, Equals, uint64(2))
+	c.Check(s.QueueDropped, Equals, uint64(2))
+	c.Check(s.QueueDropped, Equals, a.Dropped())
+}
+
+func (t *TelemetryTestSuite) TestEnableTelemetry(c *C) {
+	t.g.SetNamespace("example")
+	t.g.AddTag("test0")
+	t.g.EnableTelemetry(20 * time.Millisecond)
+
+	c.Assert(t.g.Incr("test.incr", nil), IsNil)
+
+	tags := "|c|#client:go,client_version:" + godspeed.Version + ",client_transport:custom"
+
+	var writes []string
+
+	for deadline := time.Now().Add(time.Second); len(writes) < 11 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		writes = t.m.Writes()
+	}
+
+	c.Assert(t.g.Close(), IsNil)
+
+	c.Assert(len(writes) >= 11, Equals, true)
+	c.Check(writes[:11], DeepEquals, []string{
+		"example.test.incr:1|c|#test0",
+		"datadog.dogstatsd.client.metrics:1" + tags,
+		"datadog.dogstatsd.client.events:0" + tags,
+		"datadog.dogstatsd.client.service_checks:0" + tags,
+		"datadog.dogstatsd.client.packets_sent:1" + tags,
+		"datadog.dogstatsd.client.bytes_sent:28" + tags,
+		"datadog.dogstatsd.client.packets_dropped_writer:0" + tags,
+		"datadog.dogstatsd.client.bytes_dropped_writer:0" + tags,
+		"datadog.dogstatsd.client.packets_too_large:0" + tags,
+		"datadog.dogstatsd.client.packets_truncated:0" + tags,
+		"datadog.dogstatsd.client.packets_dropped_queue:0" + tags,
+	})
+
+	// the next emission only has what's changed since the last one
+	if len(writes) > 12 {
+		c.Check(writes[11], Equals, "datadog.dogstatsd.client.metrics:0"+tags)
+		c.Check(writes[12], Equals, "datadog.dogstatsd.client.events:0"+tags)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed
+
+import "sync/atomic"
+
+// Transport is the interface Godspeed writes its emissions through, allowing
+// for sinks other than the UDP socket created by New(). Each call to Write is
+// given exactly one complete datagram, and Close is called by Godspeed.Close().
+//
+// Both *net.UDPConn and *net.UnixConn satisfy this interface.
+type Transport interface {
+	Write(b []byte) (int, error)
+	Close() error
+}
+
+// write emits a single emission, adding it to the buffer if enabled
+func (g *Godspeed) write(b []byte) (int, error) {
+	if g.buffer != nil {
+		return g.buffer.Write(b)
+	}
+
+	return g.writeTransport(b)
+}
+
+// writeTransport emits a single datagram using whichever connection is
+// available, and counts it for the telemetry
+func (g *Godspeed) writeTransport(b []byte) (n int, err error) {
+	if g.Transport != nil {
+		n, err = g.Transport.Write(b)
+	} else {
+		n, err = g.Conn.Write(b)
+	}
+
+	if err != nil {
+		g.telemetry.add(counterWriteErrors, 1)
+		g.telemetry.add(counterBytesDropped, uint64(len(b)))
+	} else {
+		g.telemetry.add(counterPackets, 1)
+		g.telemetry.add(counterBytes, uint64(n))
+	}
+
+	return
+}
+
+// connected returns whether there's a connection to write to
+func (g *Godspeed) connected() bool {
+	return g.Transport != nil || g.Conn != nil
+}
+
+// ready returns ErrNotConnected if there's no connection to write to, or
+// ErrClosed if Close() has been called
+func (g *Godspeed) ready() error {
+	if !g.connected() {
+		return ErrNotConnected
+	}
+
+	if g.closed != nil && atomic.LoadInt32(g.closed) == 1 {
+		return ErrClosed
+	}
+
+	return nil
+}
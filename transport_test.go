@@ -0,0 +1,103 @@
+// Copyright 2014-2015 PagerDuty, Inc, et al. All rights reserved.
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package godspeed_test
+
+import (
+	"sync"
+
+	"github.com/PagerDuty/godspeed"
+
+	// this is *C comes from
+	. "gopkg.in/check.v1"
+)
+
+// alwaysSample has a client emit every sampled stat, so that tests of how
+// the sample rate is sent don't depend on math/rand
+var alwaysSample = godspeed.WithSampleRateSource(func() float64 { return 0 })
+
+// memTransport is a godspeed.Transport which keeps each datagram in memory
+type memTransport struct {
+	mu     sync.Mutex
+	writes []string
+	closed bool
+}
+
+func (m *memTransport) Write(b []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writes = append(m.writes, string(b))
+
+	return len(b), nil
+}
+
+func (m *memTransport) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+
+	return nil
+}
+
+func (m *memTransport) Writes() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.writes...)
+}
+
+type TransportTestSuite struct {
+	m *memTransport
+	g *godspeed.Godspeed
+}
+
+var _ = Suite(&TransportTestSuite{})
+
+func (t *TransportTestSuite) SetUpTest(c *C) {
+	t.m = &memTransport{}
+	t.g = godspeed.NewWithTransport(t.m, false)
+}
+
+func (t *TransportTestSuite) TestNewWithTransport(c *C) {
+	c.Check(t.g.Conn, IsNil)
+	c.Check(t.g.Transport, Equals, godspeed.Transport(t.m))
+
+	c.Assert(t.g.Send("test.metric", "c", 1, 1, nil), IsNil)
+	c.Assert(t.g.Event("a", "b", nil, nil), IsNil)
+	c.Assert(t.g.ServiceCheck("testSvc", 0, nil, nil), IsNil)
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 3)
+	c.Check(writes[0], Equals, "test.metric:1|c")
+	c.Check(writes[1], Equals, "_e{1,1}:a|b")
+	c.Check(writes[2], Equals, "_sc|testSvc|0")
+
+	c.Assert(t.g.Close(), IsNil)
+	c.Check(t.m.closed, Equals, true)
+}
+
+func (t *TransportTestSuite) TestNewWithTransportAsync(c *C) {
+	a := godspeed.NewAsyncWithTransport(t.m, false)
+
+	a.W.Add(1)
+	go a.Gauge("test.gauge", 42, nil, a.W)
+	a.W.Wait()
+
+	writes := t.m.Writes()
+	c.Assert(len(writes), Equals, 1)
+	c.Check(writes[0], Equals, "test.gauge:42|g")
+}
+
+func (t *TransportTestSuite) TestNewCompatibility(c *C) {
+	g, err := godspeed.NewDefault()
+	c.Assert(err, IsNil)
+
+	defer g.Conn.Close()
+
+	// the UDP transport should still be reachable through Conn
+	c.Assert(g.Conn, NotNil)
+	c.Check(g.Transport, Equals, godspeed.Transport(g.Conn))
+}